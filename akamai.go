@@ -0,0 +1,316 @@
+package gatsbie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/retry"
+)
+
+// DefaultAkamaiRounds is the number of sensor rounds SolveAkamaiFlow tries
+// when AkamaiFlowRequest.MaxRounds is zero.
+const DefaultAkamaiRounds = 3
+
+// ErrAbckInvalid is returned by SolveAkamaiFlow when no round produced a
+// valid _abck cookie.
+var ErrAbckInvalid = errors.New("gatsbie: akamai _abck cookie not validated")
+
+// AbckStatus is the state of an Akamai _abck cookie.
+type AbckStatus int
+
+// _abck states.
+const (
+	AbckMissing AbckStatus = iota // empty or malformed
+	AbckInvalid                   // sensor data not accepted, e.g. ~-1~
+	AbckValid                     // sensor data accepted, ~0~
+)
+
+func (s AbckStatus) String() string {
+	switch s {
+	case AbckInvalid:
+		return "invalid"
+	case AbckValid:
+		return "valid"
+	}
+	return "missing"
+}
+
+// ClassifyAbck reports whether an _abck cookie value has been validated.
+// Akamai sets the second ~-separated field to 0 once it accepts the
+// sensor data.
+func ClassifyAbck(abck string) AbckStatus {
+	parts := strings.Split(abck, "~")
+	switch {
+	case len(parts) < 3:
+		return AbckMissing
+	case parts[1] == "0":
+		return AbckValid
+	}
+	return AbckInvalid
+}
+
+// AkamaiPage describes the Akamai protection found on a page.
+type AkamaiPage struct {
+	// BMPScriptURL is the classic Bot Manager sensor script, if any.
+	BMPScriptURL string
+
+	// SBSDScriptURL is the SBSD script, if any.
+	SBSDScriptURL string
+
+	// SecCPT is set when the page is the sec_cpt security checkpoint
+	// interstitial. SecCPTDuration is how long it asks the browser to
+	// wait, if stated.
+	SecCPT         bool
+	SecCPTDuration time.Duration
+}
+
+// BMP reports whether the page loads the classic Bot Manager script.
+func (p AkamaiPage) BMP() bool { return p.BMPScriptURL != "" }
+
+// SBSD reports whether the page loads the SBSD script.
+func (p AkamaiPage) SBSD() bool { return p.SBSDScriptURL != "" }
+
+var (
+	// The BMP script is served from a random extensionless path. So are
+	// many ordinary bundles, so it is only trusted on a page that shows
+	// another sign of Akamai.
+	akamaiBMPScript = regexp.MustCompile(`<script[^>]+src="(/[\w-]+(?:/[\w-]+)+)"[^>]*>\s*</script>`)
+	akamaiSignal    = regexp.MustCompile(`/akam/\d+/|\b_abck\b|\bbm_sz\b|\bak_bmsc\b`)
+	// The SBSD script carries a v=<uuid> query.
+	akamaiSBSDScript = regexp.MustCompile(`<script[^>]+src="([^"]+\?v=[0-9a-fA-F-]{36}[^"]*)"`)
+	akamaiSecCPT     = regexp.MustCompile(`sec-if-cpt-container|/_sec/cp_challenge/|sec-cp-challenge`)
+	akamaiCPTWait    = regexp.MustCompile(`(?:chlg_duration"?\s*:\s*|data-duration=")(\d+)`)
+)
+
+// DetectAkamai finds the Akamai scripts and interstitial in page, the HTML
+// of a protected page at pageURL. Script URLs are resolved against
+// pageURL. The BMP script is reported only if the page also has the
+// Akamai pixel (/akam/), mentions the _abck, bm_sz or ak_bmsc cookies, or
+// has the SBSD script or sec_cpt interstitial; otherwise pass its URL in
+// AkamaiFlowRequest.AkamaiJSURL.
+func DetectAkamai(page []byte, pageURL string) AkamaiPage {
+	var p AkamaiPage
+	base, _ := url.Parse(pageURL)
+	resolve := func(ref string) string {
+		ref = strings.ReplaceAll(ref, "&amp;", "&")
+		u, err := url.Parse(ref)
+		if err != nil || base == nil {
+			return ref
+		}
+		return base.ResolveReference(u).String()
+	}
+
+	if m := akamaiSBSDScript.FindSubmatch(page); m != nil {
+		p.SBSDScriptURL = resolve(string(m[1]))
+	}
+	if akamaiSecCPT.Match(page) {
+		p.SecCPT = true
+		if m := akamaiCPTWait.FindSubmatch(page); m != nil {
+			n, _ := strconv.Atoi(string(m[1]))
+			p.SecCPTDuration = time.Duration(n) * time.Second
+		}
+	}
+	if p.SBSD() || p.SecCPT || akamaiSignal.Match(page) {
+		if m := akamaiBMPScript.FindAllSubmatch(page, -1); m != nil {
+			// Akamai injects the sensor script last.
+			p.BMPScriptURL = resolve(string(m[len(m)-1][1]))
+		}
+	}
+	return p
+}
+
+// AkamaiFlowRequest is the request for SolveAkamaiFlow.
+type AkamaiFlowRequest struct {
+	Proxy        string
+	TargetURL    string
+	TargetMethod string // for SBSD; defaults to GET
+
+	// Page is the HTML of the protected page. It is used to find the
+	// Akamai scripts and whether SBSD is in use.
+	Page []byte
+
+	// AkamaiJSURL is the Bot Manager script URL. It overrides the one
+	// found in Page.
+	AkamaiJSURL string
+	PageFP      string
+
+	// MaxRounds is the most SolveAkamai calls made to get a valid _abck.
+	// Zero means DefaultAkamaiRounds.
+	MaxRounds int
+
+	// SkipSecCPTWait skips waiting out a sec_cpt checkpoint found in
+	// Page, for callers that have already waited.
+	SkipSecCPTWait bool
+}
+
+// AkamaiCookieSet is the consolidated set of Akamai cookies.
+type AkamaiCookieSet struct {
+	Abck string
+	BmSz string
+	BmS  string
+	BmSc string
+
+	// Other holds any further cookies the API returned, such as Country.
+	Other map[string]string
+}
+
+// Cookies returns the set as cookies, omitting empty values.
+func (s AkamaiCookieSet) Cookies() []*http.Cookie {
+	var cookies []*http.Cookie
+	add := func(name, value string) {
+		if value != "" {
+			cookies = append(cookies, &http.Cookie{Name: name, Value: value})
+		}
+	}
+	add("_abck", s.Abck)
+	add("bm_sz", s.BmSz)
+	add("bm_s", s.BmS)
+	add("bm_sc", s.BmSc)
+	for name, value := range s.Other {
+		add(name, value)
+	}
+	return cookies
+}
+
+// AddTo adds the cookies to req.
+func (s AkamaiCookieSet) AddTo(req *http.Request) {
+	for _, c := range s.Cookies() {
+		req.AddCookie(c)
+	}
+}
+
+// AkamaiFlowResult is returned by SolveAkamaiFlow.
+type AkamaiFlowResult struct {
+	Cookies   AkamaiCookieSet
+	UserAgent string
+	Page      AkamaiPage
+
+	// AbckStatus is the state of Cookies.Abck after the last round.
+	AbckStatus AbckStatus
+
+	// Rounds is the number of SolveAkamai calls made.
+	Rounds int
+
+	// Cost is the total charged across all solves.
+	Cost float64
+}
+
+// SolveAkamaiFlow runs the full Akamai flow for a protected page. It
+// solves SBSD when Page loads the SBSD script, then calls SolveAkamai
+// until the _abck cookie is valid or MaxRounds is reached.
+//
+// If Page is the sec_cpt interstitial, SolveAkamaiFlow first waits the
+// duration the checkpoint asks for, unless SkipSecCPTWait is set, since
+// Akamai only accepts sensor data posted after it. The API has no
+// checkpoint task: the caller must reload TargetURL with the returned
+// cookies, and run the flow again on the new page if the checkpoint is
+// served again.
+//
+// If no round produced a valid _abck, the result is returned along with
+// an error wrapping ErrAbckInvalid. If a solve or the checkpoint wait
+// fails, the result so far, with the Rounds and Cost already spent, is
+// returned along with the error. A key set with WithIdempotencyKey is
+// used for the first solve and suffixed with -2, -3, ... for later ones.
+func (c *Client) SolveAkamaiFlow(ctx context.Context, req *AkamaiFlowRequest, opts ...CallOption) (*AkamaiFlowResult, error) {
+	page := DetectAkamai(req.Page, req.TargetURL)
+	if req.AkamaiJSURL != "" {
+		page.BMPScriptURL = req.AkamaiJSURL
+	}
+	if !page.BMP() && !page.SBSD() {
+		return nil, invalidRequest("akamai: no Bot Manager or SBSD script found on page; set AkamaiJSURL")
+	}
+
+	result := &AkamaiFlowResult{Page: page}
+	if page.SecCPT && !req.SkipSecCPTWait {
+		if err := retry.Sleep(ctx, page.SecCPTDuration); err != nil {
+			return result, err
+		}
+	}
+	solves := 0 // numbers each solve for attemptContext
+	if page.SBSD() {
+		solves++
+		method := req.TargetMethod
+		if method == "" {
+			method = http.MethodGet
+		}
+		resp, err := c.SolveSBSD(attemptContext(ctx, solves), &SBSDRequest{
+			Proxy:        req.Proxy,
+			TargetURL:    req.TargetURL,
+			TargetMethod: method,
+		}, opts...)
+		if err != nil {
+			return result, err
+		}
+		result.Cookies.BmS = resp.Solution.BmS
+		result.Cookies.BmSc = resp.Solution.BmSc
+		result.UserAgent = resp.Solution.UserAgent
+		result.Cost += resp.Cost
+	}
+	if !page.BMP() {
+		return result, nil
+	}
+
+	rounds := req.MaxRounds
+	if rounds <= 0 {
+		rounds = DefaultAkamaiRounds
+	}
+	for result.Rounds < rounds {
+		solves++
+		resp, err := c.SolveAkamai(attemptContext(ctx, solves), &AkamaiRequest{
+			Proxy:       req.Proxy,
+			TargetURL:   req.TargetURL,
+			AkamaiJSURL: page.BMPScriptURL,
+			PageFP:      req.PageFP,
+		}, opts...)
+		if err != nil {
+			return result, err
+		}
+		result.Rounds++
+		result.Cost += resp.Cost
+		result.mergeAkamai(resp.Solution)
+		if result.AbckStatus == AbckValid {
+			return result, nil
+		}
+	}
+	return result, fmt.Errorf("%w after %d rounds (%s)", ErrAbckInvalid, result.Rounds, result.AbckStatus)
+}
+
+// mergeAkamai replaces the Bot Manager cookies with those of a new round.
+func (r *AkamaiFlowResult) mergeAkamai(s AkamaiSolution) {
+	d := s.CookiesDict
+	r.Cookies.Abck = d.Abck
+	r.Cookies.BmSz = d.BmSz
+	r.AbckStatus = ClassifyAbck(d.Abck)
+	if s.UserAgent != "" {
+		r.UserAgent = s.UserAgent
+	}
+
+	other := map[string]string{}
+	if d.Country != "" {
+		other["Country"] = d.Country
+	}
+	if d.UsrLocale != "" {
+		other["UsrLocale"] = d.UsrLocale
+	}
+	for name, v := range d.Extra {
+		switch v := v.(type) {
+		case string:
+			other[name] = v
+		case nil:
+		default:
+			other[name] = fmt.Sprint(v)
+		}
+	}
+	if len(other) > 0 {
+		r.Cookies.Other = other
+	} else {
+		r.Cookies.Other = nil
+	}
+}
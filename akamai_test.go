@@ -0,0 +1,251 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+const (
+	akamaiPageURL = "https://www.example.com/products/1"
+	akamaiBMPURL  = "https://www.example.com/aBcD3fGh/iJkL/mNoP/qRsT/uVwX5yZ/N0EyLXZx/d3E2Y/mFvSw"
+)
+
+func akamaiSolution(abck string) gatsbietest.Response {
+	return gatsbietest.Solution(gatsbie.AkamaiSolution{
+		CookiesDict: gatsbie.AkamaiCookies{Abck: abck, BmSz: "bm-sz-" + abck, Country: "US"},
+		UserAgent:   gatsbietest.DefaultUserAgent,
+	}).WithCost(0.002)
+}
+
+func TestClassifyAbck(t *testing.T) {
+	tests := []struct {
+		abck string
+		want gatsbie.AbckStatus
+	}{
+		{"", gatsbie.AbckMissing},
+		{"garbage", gatsbie.AbckMissing},
+		{"A1B2C3~0~YAAQ~-1~-1~-1", gatsbie.AbckValid},
+		{"A1B2C3~-1~YAAQ~-1~-1~-1", gatsbie.AbckInvalid},
+		{"A1B2C3~-1~YAAQ~0~-1~-1", gatsbie.AbckInvalid},
+	}
+	for _, tt := range tests {
+		if got := gatsbie.ClassifyAbck(tt.abck); got != tt.want {
+			t.Errorf("ClassifyAbck(%q) = %s, want %s", tt.abck, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAkamai(t *testing.T) {
+	tests := []struct {
+		fixture string
+		bmp     string
+		sbsd    string
+		secCPT  bool
+		wait    time.Duration
+	}{
+		{fixture: "bmp.html", bmp: akamaiBMPURL},
+		{
+			fixture: "sbsd.html",
+			bmp:     akamaiBMPURL,
+			sbsd:    "https://www.example.com/.well-known/sbsd/4kHm7q?v=9c2e51b7-3f0d-4a8e-b6c1-2d7f8e9a0b13&t=482113007",
+		},
+		{fixture: "sec_cpt.html", bmp: akamaiBMPURL, secCPT: true, wait: 30 * time.Second},
+		{fixture: "none.html"},
+		// Extensionless bundles are not the BMP script without an Akamai
+		// signal on the page.
+		{fixture: "bundles.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			p := gatsbie.DetectAkamai(readFixture(t, "akamai", tt.fixture), akamaiPageURL)
+			if p.BMPScriptURL != tt.bmp {
+				t.Errorf("BMPScriptURL = %q, want %q", p.BMPScriptURL, tt.bmp)
+			}
+			if p.SBSDScriptURL != tt.sbsd {
+				t.Errorf("SBSDScriptURL = %q, want %q", p.SBSDScriptURL, tt.sbsd)
+			}
+			if p.SecCPT != tt.secCPT || p.SecCPTDuration != tt.wait {
+				t.Errorf("SecCPT = %v, %s, want %v, %s", p.SecCPT, p.SecCPTDuration, tt.secCPT, tt.wait)
+			}
+		})
+	}
+}
+
+func TestSolveAkamaiFlowRetriesUntilValid(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai,
+		akamaiSolution("abck~-1~first"),
+		akamaiSolution("abck~0~second"),
+	)
+
+	res, err := srv.Client().SolveAkamaiFlow(context.Background(), &gatsbie.AkamaiFlowRequest{
+		Proxy:     testProxy,
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "bmp.html"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rounds != 2 || res.AbckStatus != gatsbie.AbckValid {
+		t.Errorf("Rounds = %d, AbckStatus = %s", res.Rounds, res.AbckStatus)
+	}
+	if res.Cookies.Abck != "abck~0~second" || res.Cookies.BmSz != "bm-sz-abck~0~second" {
+		t.Errorf("Cookies = %+v", res.Cookies)
+	}
+	if res.Cookies.Other["Country"] != "US" {
+		t.Errorf("Other = %v", res.Cookies.Other)
+	}
+	if res.Cost != 0.004 {
+		t.Errorf("Cost = %v, want 0.004", res.Cost)
+	}
+	srv.ExpectField(gatsbietest.TaskAkamai, "akamai_js_url", akamaiBMPURL)
+	if n := len(srv.Requests(gatsbietest.TaskSBSD)); n != 0 {
+		t.Errorf("SBSD solved %d times on a BMP-only page", n)
+	}
+}
+
+func TestSolveAkamaiFlowGivesUp(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, akamaiSolution("abck~-1~never"))
+
+	res, err := srv.Client().SolveAkamaiFlow(context.Background(), &gatsbie.AkamaiFlowRequest{
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "bmp.html"),
+		MaxRounds: 2,
+	})
+	if !errors.Is(err, gatsbie.ErrAbckInvalid) {
+		t.Fatalf("err = %v, want ErrAbckInvalid", err)
+	}
+	if res == nil || res.Rounds != 2 || res.Cookies.Abck != "abck~-1~never" {
+		t.Errorf("result = %+v", res)
+	}
+	if n := len(srv.Requests(gatsbietest.TaskAkamai)); n != 2 {
+		t.Errorf("SolveAkamai called %d times, want 2", n)
+	}
+}
+
+func TestSolveAkamaiFlowSBSD(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, akamaiSolution("abck~0~ok"))
+
+	res, err := srv.Client().SolveAkamaiFlow(context.Background(), &gatsbie.AkamaiFlowRequest{
+		TargetURL:    akamaiPageURL,
+		TargetMethod: http.MethodPost,
+		Page:         readFixture(t, "akamai", "sbsd.html"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskSBSD, "target_method", "POST")
+	srv.ExpectField(gatsbietest.TaskSBSD, "target_url", akamaiPageURL)
+	c := res.Cookies
+	if c.BmS == "" || c.BmSc == "" || c.Abck != "abck~0~ok" || c.BmSz == "" {
+		t.Errorf("Cookies = %+v", c)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, akamaiPageURL, nil)
+	c.AddTo(req)
+	for _, name := range []string{"_abck", "bm_sz", "bm_s", "bm_sc", "Country"} {
+		if _, err := req.Cookie(name); err != nil {
+			t.Errorf("cookie %s not added", name)
+		}
+	}
+}
+
+func TestSolveAkamaiFlowNoScript(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	_, err := srv.Client().SolveAkamaiFlow(context.Background(), &gatsbie.AkamaiFlowRequest{
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "none.html"),
+	})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want INVALID_REQUEST", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("API called %d times", n)
+	}
+}
+
+func TestSolveAkamaiFlowIdempotencyKeys(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, akamaiSolution("abck~-1~first"), akamaiSolution("abck~0~second"))
+
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "job-7")
+	if _, err := srv.Client().SolveAkamaiFlow(ctx, &gatsbie.AkamaiFlowRequest{
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "sbsd.html"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, r := range srv.Requests() {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+	}
+	want := []string{"job-7", "job-7-2", "job-7-3"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %q, want %q", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("keys = %q, want %q", keys, want)
+			break
+		}
+	}
+}
+
+func TestSolveAkamaiFlowPartialResult(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, akamaiSolution("abck~-1~first"), gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no sensor"))
+
+	res, err := srv.Client().SolveAkamaiFlow(context.Background(), &gatsbie.AkamaiFlowRequest{
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "bmp.html"),
+	})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsSolveFailed() {
+		t.Fatalf("err = %v, want SOLVE_FAILED", err)
+	}
+	if res == nil || res.Rounds != 1 || res.Cost != 0.002 || res.Cookies.Abck != "abck~-1~first" {
+		t.Errorf("result = %+v, want the first round", res)
+	}
+}
+
+func TestSolveAkamaiFlowSecCPT(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, akamaiSolution("abck~0~ok"))
+	req := &gatsbie.AkamaiFlowRequest{
+		TargetURL: akamaiPageURL,
+		Page:      readFixture(t, "akamai", "sec_cpt.html"),
+	}
+
+	// The checkpoint asks for 30 seconds, so the flow is still waiting
+	// when the context ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res, err := srv.Client().SolveAkamaiFlow(ctx, req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if res == nil || !res.Page.SecCPT {
+		t.Errorf("result = %+v, want the sec_cpt page", res)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Fatalf("API called %d times during the checkpoint wait", n)
+	}
+
+	req.SkipSecCPTWait = true
+	res, err = srv.Client().SolveAkamaiFlow(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AbckStatus != gatsbie.AbckValid || res.Page.SecCPTDuration != 30*time.Second {
+		t.Errorf("result = %+v", res)
+	}
+}
@@ -0,0 +1,337 @@
+package gatsbie
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Browser is a browser family recognized by ParseUserAgent.
+type Browser string
+
+// Browsers recognized by ParseUserAgent.
+const (
+	BrowserUnknown Browser = ""
+	BrowserChrome  Browser = "chrome"
+	BrowserEdge    Browser = "edge"
+	BrowserFirefox Browser = "firefox"
+	BrowserSafari  Browser = "safari"
+)
+
+// Platforms reported by ParseUserAgent, as spelled in sec-ch-ua-platform.
+const (
+	PlatformWindows  = "Windows"
+	PlatformMacOS    = "macOS"
+	PlatformLinux    = "Linux"
+	PlatformChromeOS = "Chrome OS"
+	PlatformAndroid  = "Android"
+	PlatformIOS      = "iOS"
+)
+
+// defaultAcceptLanguage is used when BrowserProfile.AcceptLanguage is
+// empty.
+const defaultAcceptLanguage = "en-US,en;q=0.9"
+
+// BrowserProfile describes the browser a User-Agent string claims to be.
+// Its header methods return the headers that browser sends, in its order,
+// so that follow-up requests match the solution's User-Agent.
+type BrowserProfile struct {
+	UserAgent string
+	Browser   Browser
+	Version   int // major version, such as 124
+	Platform  string
+	Mobile    bool
+
+	// AcceptLanguage is sent as Accept-Language. The default is
+	// "en-US,en;q=0.9", or "en-US,en;q=0.5" for Firefox.
+	AcceptLanguage string
+}
+
+// Header is a single header field.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Headers is an ordered list of header fields.
+type Headers []Header
+
+// Get returns the value of the named header, ignoring case.
+func (h Headers) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Names returns the header names in order, for HTTP clients that can send
+// headers in a fixed order.
+func (h Headers) Names() []string {
+	names := make([]string, len(h))
+	for i, f := range h {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Apply sets the headers on req, replacing existing values. net/http
+// does not preserve header order; use Names with a client that does.
+func (h Headers) Apply(req *http.Request) {
+	for _, f := range h {
+		req.Header.Set(f.Name, f.Value)
+	}
+}
+
+var (
+	edgeVersion    = regexp.MustCompile(`\bEdg(?:e|A|iOS)?/(\d+)`)
+	chromeVersion  = regexp.MustCompile(`\b(?:Chrome|CriOS)/(\d+)`)
+	firefoxVersion = regexp.MustCompile(`\b(?:Firefox|FxiOS)/(\d+)`)
+	safariVersion  = regexp.MustCompile(`\bVersion/(\d+)`)
+)
+
+// ParseUserAgent identifies the browser, major version and platform in
+// ua. Chrome, Edge, Firefox and Safari are recognized; anything else has
+// Browser set to BrowserUnknown.
+func ParseUserAgent(ua string) BrowserProfile {
+	p := BrowserProfile{UserAgent: ua, Platform: parsePlatform(ua)}
+	p.Mobile = strings.Contains(ua, "Mobile") || strings.Contains(ua, "iPhone")
+
+	match := func(re *regexp.Regexp) bool {
+		m := re.FindStringSubmatch(ua)
+		if m == nil {
+			return false
+		}
+		p.Version, _ = strconv.Atoi(m[1])
+		return true
+	}
+	switch {
+	case match(edgeVersion):
+		p.Browser = BrowserEdge
+	case strings.Contains(ua, "OPR/") || strings.Contains(ua, "SamsungBrowser/"):
+		// Other Chromium browsers send their own brands.
+	case match(chromeVersion):
+		p.Browser = BrowserChrome
+	case match(firefoxVersion):
+		p.Browser = BrowserFirefox
+	case strings.Contains(ua, "Safari/") && match(safariVersion):
+		p.Browser = BrowserSafari
+	}
+	return p
+}
+
+func parsePlatform(ua string) string {
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return PlatformIOS
+	case strings.Contains(ua, "Android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "Windows"):
+		return PlatformWindows
+	case strings.Contains(ua, "CrOS"):
+		return PlatformChromeOS
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		return PlatformMacOS
+	case strings.Contains(ua, "Linux"), strings.Contains(ua, "X11"):
+		return PlatformLinux
+	}
+	return ""
+}
+
+// BrowserProfileOf parses the UserAgent field of a solution, such as
+// DatadomeSolution or Reese84Solution, or of a SolveResponse holding one.
+// It reports false if the solution has no User-Agent.
+func BrowserProfileOf(solution any) (BrowserProfile, bool) {
+	v := reflect.ValueOf(solution)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return BrowserProfile{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return BrowserProfile{}, false
+	}
+	if f := v.FieldByName("Solution"); f.IsValid() {
+		return BrowserProfileOf(f.Interface())
+	}
+	f := v.FieldByName("UserAgent")
+	if !f.IsValid() || f.Kind() != reflect.String || f.String() == "" {
+		return BrowserProfile{}, false
+	}
+	return ParseUserAgent(f.String()), true
+}
+
+// chromium reports whether the profile sends Chromium client hints.
+// Chrome and Edge on iOS use WebKit and send Safari's headers.
+func (p BrowserProfile) chromium() bool {
+	return (p.Browser == BrowserChrome || p.Browser == BrowserEdge) && p.Platform != PlatformIOS
+}
+
+func (p BrowserProfile) acceptLanguage() string {
+	switch {
+	case p.AcceptLanguage != "":
+		return p.AcceptLanguage
+	case p.Browser == BrowserFirefox:
+		return "en-US,en;q=0.5"
+	}
+	return defaultAcceptLanguage
+}
+
+// NavigationHeaders returns the headers the browser sends when loading a
+// page, as for a top-level navigation.
+func (p BrowserProfile) NavigationHeaders() Headers {
+	switch {
+	case p.chromium():
+		return Headers{
+			{"sec-ch-ua", p.secCHUA()},
+			{"sec-ch-ua-mobile", p.secCHUAMobile()},
+			{"sec-ch-ua-platform", strconv.Quote(p.Platform)},
+			{"upgrade-insecure-requests", "1"},
+			{"user-agent", p.UserAgent},
+			{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+			{"sec-fetch-site", "none"},
+			{"sec-fetch-mode", "navigate"},
+			{"sec-fetch-user", "?1"},
+			{"sec-fetch-dest", "document"},
+			{"accept-encoding", p.acceptEncoding()},
+			{"accept-language", p.acceptLanguage()},
+			{"priority", "u=0, i"},
+		}
+	case p.Browser == BrowserFirefox:
+		accept := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+		if p.Version < 128 {
+			accept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+		}
+		return Headers{
+			{"user-agent", p.UserAgent},
+			{"accept", accept},
+			{"accept-language", p.acceptLanguage()},
+			{"accept-encoding", p.acceptEncoding()},
+			{"upgrade-insecure-requests", "1"},
+			{"sec-fetch-dest", "document"},
+			{"sec-fetch-mode", "navigate"},
+			{"sec-fetch-site", "none"},
+			{"sec-fetch-user", "?1"},
+			{"priority", "u=0, i"},
+			{"te", "trailers"},
+		}
+	case p.Browser == BrowserSafari || p.Platform == PlatformIOS:
+		return Headers{
+			{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"sec-fetch-site", "none"},
+			{"sec-fetch-dest", "document"},
+			{"accept-language", p.acceptLanguage()},
+			{"sec-fetch-mode", "navigate"},
+			{"user-agent", p.UserAgent},
+			{"accept-encoding", p.acceptEncoding()},
+		}
+	}
+	return Headers{
+		{"user-agent", p.UserAgent},
+		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+		{"accept-language", p.acceptLanguage()},
+		{"accept-encoding", "gzip, deflate, br"},
+	}
+}
+
+// FetchHeaders returns the headers the browser sends for a same-origin
+// fetch or XHR call from a page, such as a protected API call.
+func (p BrowserProfile) FetchHeaders() Headers {
+	switch {
+	case p.chromium():
+		return Headers{
+			{"sec-ch-ua-platform", strconv.Quote(p.Platform)},
+			{"user-agent", p.UserAgent},
+			{"sec-ch-ua", p.secCHUA()},
+			{"sec-ch-ua-mobile", p.secCHUAMobile()},
+			{"accept", "*/*"},
+			{"sec-fetch-site", "same-origin"},
+			{"sec-fetch-mode", "cors"},
+			{"sec-fetch-dest", "empty"},
+			{"accept-encoding", p.acceptEncoding()},
+			{"accept-language", p.acceptLanguage()},
+			{"priority", "u=1, i"},
+		}
+	case p.Browser == BrowserFirefox:
+		return Headers{
+			{"user-agent", p.UserAgent},
+			{"accept", "*/*"},
+			{"accept-language", p.acceptLanguage()},
+			{"accept-encoding", p.acceptEncoding()},
+			{"sec-fetch-dest", "empty"},
+			{"sec-fetch-mode", "cors"},
+			{"sec-fetch-site", "same-origin"},
+			{"priority", "u=4"},
+			{"te", "trailers"},
+		}
+	case p.Browser == BrowserSafari || p.Platform == PlatformIOS:
+		return Headers{
+			{"accept", "*/*"},
+			{"sec-fetch-site", "same-origin"},
+			{"sec-fetch-dest", "empty"},
+			{"accept-language", p.acceptLanguage()},
+			{"sec-fetch-mode", "cors"},
+			{"user-agent", p.UserAgent},
+			{"accept-encoding", p.acceptEncoding()},
+		}
+	}
+	return Headers{
+		{"user-agent", p.UserAgent},
+		{"accept", "*/*"},
+		{"accept-language", p.acceptLanguage()},
+		{"accept-encoding", "gzip, deflate, br"},
+	}
+}
+
+func (p BrowserProfile) acceptEncoding() string {
+	switch {
+	case p.chromium() && p.Version >= 123, p.Browser == BrowserFirefox && p.Version >= 126:
+		return "gzip, deflate, br, zstd"
+	}
+	return "gzip, deflate, br"
+}
+
+func (p BrowserProfile) secCHUAMobile() string {
+	if p.Mobile {
+		return "?1"
+	}
+	return "?0"
+}
+
+// GREASE values Chromium mixes into sec-ch-ua, chosen by major version.
+var (
+	greaseChars    = []string{" ", "(", ":", "-", ".", "/", ")", ";", "=", "?", "_"}
+	greaseVersions = []string{"8", "99", "24"}
+	greaseOrders   = [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+)
+
+// secCHUA builds the sec-ch-ua brand list the way Chromium does: a GREASE
+// brand, Chromium and the browser's own brand, in an order derived from
+// the major version.
+func (p BrowserProfile) secCHUA() string {
+	seed := p.Version
+	brand, brandVersion := "Google Chrome", p.Version
+	if p.Browser == BrowserEdge {
+		// Edge's GREASE and Chromium version follow its Chrome token.
+		if m := chromeVersion.FindStringSubmatch(p.UserAgent); m != nil {
+			seed, _ = strconv.Atoi(m[1])
+		}
+		brand = "Microsoft Edge"
+	}
+	v := strconv.Itoa(seed)
+
+	brands := [3]string{
+		`"Not` + greaseChars[seed%len(greaseChars)] + "A" + greaseChars[(seed+1)%len(greaseChars)] + `Brand";v="` + greaseVersions[seed%len(greaseVersions)] + `"`,
+		`"Chromium";v="` + v + `"`,
+		`"` + brand + `";v="` + strconv.Itoa(brandVersion) + `"`,
+	}
+	var ordered [3]string
+	for i, pos := range greaseOrders[seed%len(greaseOrders)] {
+		ordered[pos] = brands[i]
+	}
+	return strings.Join(ordered[:], ", ")
+}
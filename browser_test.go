@@ -0,0 +1,214 @@
+package gatsbie
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const (
+	chromeWindowsUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	chromeMacUA      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+	chromeLinuxUA    = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	chromeAndroidUA  = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	chromeIOSUA      = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1"
+	edgeWindowsUA    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0"
+	firefoxWindowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	firefoxLinuxUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:115.0) Gecko/20100101 Firefox/115.0"
+	safariMacUA      = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15"
+	safariIOSUA      = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name     string
+		ua       string
+		browser  Browser
+		version  int
+		platform string
+		mobile   bool
+	}{
+		{"chrome windows", chromeWindowsUA, BrowserChrome, 124, PlatformWindows, false},
+		{"chrome mac", chromeMacUA, BrowserChrome, 131, PlatformMacOS, false},
+		{"chrome linux", chromeLinuxUA, BrowserChrome, 120, PlatformLinux, false},
+		{"chrome android", chromeAndroidUA, BrowserChrome, 124, PlatformAndroid, true},
+		{"chrome ios", chromeIOSUA, BrowserChrome, 124, PlatformIOS, true},
+		{"edge windows", edgeWindowsUA, BrowserEdge, 124, PlatformWindows, false},
+		{"firefox windows", firefoxWindowsUA, BrowserFirefox, 128, PlatformWindows, false},
+		{"firefox linux", firefoxLinuxUA, BrowserFirefox, 115, PlatformLinux, false},
+		{"safari mac", safariMacUA, BrowserSafari, 17, PlatformMacOS, false},
+		{"safari ios", safariIOSUA, BrowserSafari, 17, PlatformIOS, true},
+		{"unknown", "curl/8.4.0", BrowserUnknown, 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ParseUserAgent(tt.ua)
+			if p.Browser != tt.browser || p.Version != tt.version || p.Platform != tt.platform || p.Mobile != tt.mobile {
+				t.Errorf("ParseUserAgent = %s %d %q mobile=%v, want %s %d %q mobile=%v",
+					p.Browser, p.Version, p.Platform, p.Mobile, tt.browser, tt.version, tt.platform, tt.mobile)
+			}
+			if p.UserAgent != tt.ua {
+				t.Errorf("UserAgent = %q, want %q", p.UserAgent, tt.ua)
+			}
+		})
+	}
+}
+
+func TestNavigationHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		ua       string
+		secCHUA  string
+		platform string
+		mobile   string
+		encoding string
+		language string
+		order    []string
+	}{
+		{
+			name:     "chrome 124 windows",
+			ua:       chromeWindowsUA,
+			secCHUA:  `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+			platform: `"Windows"`,
+			mobile:   "?0",
+			encoding: "gzip, deflate, br, zstd",
+			language: "en-US,en;q=0.9",
+			order:    []string{"sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform", "upgrade-insecure-requests", "user-agent", "accept", "sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest", "accept-encoding", "accept-language", "priority"},
+		},
+		{
+			name:     "chrome 131 mac",
+			ua:       chromeMacUA,
+			secCHUA:  `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`,
+			platform: `"macOS"`,
+			mobile:   "?0",
+			encoding: "gzip, deflate, br, zstd",
+			language: "en-US,en;q=0.9",
+		},
+		{
+			name:     "chrome 120 linux",
+			ua:       chromeLinuxUA,
+			secCHUA:  `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+			platform: `"Linux"`,
+			mobile:   "?0",
+			encoding: "gzip, deflate, br",
+			language: "en-US,en;q=0.9",
+		},
+		{
+			name:     "chrome android",
+			ua:       chromeAndroidUA,
+			secCHUA:  `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+			platform: `"Android"`,
+			mobile:   "?1",
+			encoding: "gzip, deflate, br, zstd",
+			language: "en-US,en;q=0.9",
+		},
+		{
+			name:     "edge windows",
+			ua:       edgeWindowsUA,
+			secCHUA:  `"Chromium";v="124", "Microsoft Edge";v="124", "Not-A.Brand";v="99"`,
+			platform: `"Windows"`,
+			mobile:   "?0",
+			encoding: "gzip, deflate, br, zstd",
+			language: "en-US,en;q=0.9",
+		},
+		{
+			name:     "firefox windows",
+			ua:       firefoxWindowsUA,
+			encoding: "gzip, deflate, br, zstd",
+			language: "en-US,en;q=0.5",
+			order:    []string{"user-agent", "accept", "accept-language", "accept-encoding", "upgrade-insecure-requests", "sec-fetch-dest", "sec-fetch-mode", "sec-fetch-site", "sec-fetch-user", "priority", "te"},
+		},
+		{
+			name:     "firefox 115 linux",
+			ua:       firefoxLinuxUA,
+			encoding: "gzip, deflate, br",
+			language: "en-US,en;q=0.5",
+		},
+		{
+			name:     "safari mac",
+			ua:       safariMacUA,
+			encoding: "gzip, deflate, br",
+			language: "en-US,en;q=0.9",
+			order:    []string{"accept", "sec-fetch-site", "sec-fetch-dest", "accept-language", "sec-fetch-mode", "user-agent", "accept-encoding"},
+		},
+		{
+			name:     "chrome ios uses safari headers",
+			ua:       chromeIOSUA,
+			encoding: "gzip, deflate, br",
+			language: "en-US,en;q=0.9",
+			order:    []string{"accept", "sec-fetch-site", "sec-fetch-dest", "accept-language", "sec-fetch-mode", "user-agent", "accept-encoding"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseUserAgent(tt.ua).NavigationHeaders()
+			for name, want := range map[string]string{
+				"sec-ch-ua":          tt.secCHUA,
+				"sec-ch-ua-platform": tt.platform,
+				"sec-ch-ua-mobile":   tt.mobile,
+				"accept-encoding":    tt.encoding,
+				"accept-language":    tt.language,
+				"user-agent":         tt.ua,
+			} {
+				if got := h.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.order != nil && !reflect.DeepEqual(h.Names(), tt.order) {
+				t.Errorf("order = %q, want %q", h.Names(), tt.order)
+			}
+		})
+	}
+}
+
+func TestFetchHeaders(t *testing.T) {
+	h := ParseUserAgent(chromeWindowsUA).FetchHeaders()
+	want := []string{"sec-ch-ua-platform", "user-agent", "sec-ch-ua", "sec-ch-ua-mobile", "accept", "sec-fetch-site", "sec-fetch-mode", "sec-fetch-dest", "accept-encoding", "accept-language", "priority"}
+	if !reflect.DeepEqual(h.Names(), want) {
+		t.Errorf("order = %q, want %q", h.Names(), want)
+	}
+	if got := h.Get("sec-fetch-mode"); got != "cors" {
+		t.Errorf("sec-fetch-mode = %q, want cors", got)
+	}
+	if got := ParseUserAgent(firefoxWindowsUA).FetchHeaders().Get("sec-ch-ua"); got != "" {
+		t.Errorf("firefox sent sec-ch-ua %q", got)
+	}
+}
+
+func TestBrowserProfileAcceptLanguage(t *testing.T) {
+	p := ParseUserAgent(chromeWindowsUA)
+	p.AcceptLanguage = "de-DE,de;q=0.9"
+	if got := p.NavigationHeaders().Get("accept-language"); got != "de-DE,de;q=0.9" {
+		t.Errorf("accept-language = %q", got)
+	}
+}
+
+func TestBrowserProfileOf(t *testing.T) {
+	resp := &SolveResponse[DatadomeSolution]{Solution: DatadomeSolution{UserAgent: edgeWindowsUA}}
+	p, ok := BrowserProfileOf(resp)
+	if !ok || p.Browser != BrowserEdge {
+		t.Errorf("BrowserProfileOf(response) = %+v, %v", p, ok)
+	}
+	p, ok = BrowserProfileOf(Reese84Solution{UserAgent: safariMacUA})
+	if !ok || p.Browser != BrowserSafari {
+		t.Errorf("BrowserProfileOf(solution) = %+v, %v", p, ok)
+	}
+	if _, ok := BrowserProfileOf(DatadomeSolution{}); ok {
+		t.Error("BrowserProfileOf reported a profile for an empty User-Agent")
+	}
+	if _, ok := BrowserProfileOf((*DatadomeSolution)(nil)); ok {
+		t.Error("BrowserProfileOf reported a profile for nil")
+	}
+}
+
+func TestHeadersApply(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+	ParseUserAgent(chromeWindowsUA).NavigationHeaders().Apply(req)
+	if got := req.Header.Get("User-Agent"); got != chromeWindowsUA {
+		t.Errorf("User-Agent = %q", got)
+	}
+	if got := req.Header.Get("Sec-Ch-Ua-Platform"); got != `"Windows"` {
+		t.Errorf("Sec-Ch-Ua-Platform = %q", got)
+	}
+}
@@ -0,0 +1,29 @@
+package gatsbie
+
+import "time"
+
+// CallOption configures a single API call, overriding client settings for
+// that call only.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout time.Duration
+}
+
+// WithCallTimeout sets a deadline for the whole call, including any
+// retries and failover, replacing the client's per-request timeout. Use it
+// to give slow solves such as Akamai more time than fast ones such as
+// Turnstile.
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -3,55 +3,61 @@ package gatsbie
 import "context"
 
 // Health checks the API server health status.
-func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+func (c *Client) Health(ctx context.Context, opts ...CallOption) (*HealthResponse, error) {
 	var resp HealthResponse
-	if err := c.doGet(ctx, "/health", &resp); err != nil {
+	if err := c.doGet(ctx, "/health", &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveDatadome solves a Datadome device check challenge.
-func (c *Client) SolveDatadome(ctx context.Context, req *DatadomeRequest) (*SolveResponse[DatadomeSolution], error) {
+func (c *Client) SolveDatadome(ctx context.Context, req *DatadomeRequest, opts ...CallOption) (*SolveResponse[DatadomeSolution], error) {
 	internal := datadomeRequestInternal{
 		TaskType:     "datadome-device-check",
-		Proxy:        req.Proxy,
+		Proxy:        c.proxy(req.Proxy),
 		TargetURL:    req.TargetURL,
 		TargetMethod: req.TargetMethod,
 	}
 
 	var resp SolveResponse[DatadomeSolution]
-	if err := c.doPost(ctx, "/v1/solve/datadome-device-check", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/datadome-device-check", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
-// SolveRecaptcha solves a reCAPTCHA v2/v3 (Universal) challenge.
-func (c *Client) SolveRecaptcha(ctx context.Context, req *RecaptchaRequest) (*SolveResponse[RecaptchaSolution], error) {
+// SolveRecaptcha solves a reCAPTCHA v2/v3 (Universal) challenge. If
+// req.MinScore is set, low-scoring solutions are solved again; see
+// RecaptchaRequest.MinScore.
+func (c *Client) SolveRecaptcha(ctx context.Context, req *RecaptchaRequest, opts ...CallOption) (*SolveResponse[RecaptchaSolution], error) {
 	internal := recaptchaRequestInternal{
 		TaskType:  "recaptcha",
-		Proxy:     req.Proxy,
+		Proxy:     c.proxy(req.Proxy),
 		TargetURL: req.TargetURL,
 		SiteKey:   req.SiteKey,
 		Size:      req.Size,
 		Title:     req.Title,
 		Action:    req.Action,
 		Ubd:       req.Ubd,
+		MinScore:  req.MinScore,
 	}
 
-	var resp SolveResponse[RecaptchaSolution]
-	if err := c.doPost(ctx, "/v1/solve/recaptcha", internal, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+	return solveForScore(ctx, req.MinScore, req.MaxAttempts, func(ctx context.Context) (*SolveResponse[RecaptchaSolution], error) {
+		var resp SolveResponse[RecaptchaSolution]
+		if err := c.doPost(ctx, "/v1/solve/recaptcha", internal, &resp, opts); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	})
 }
 
-// SolveRecaptchaEnterprise solves a reCAPTCHA Enterprise challenge.
-func (c *Client) SolveRecaptchaEnterprise(ctx context.Context, req *RecaptchaEnterpriseRequest) (*SolveResponse[RecaptchaSolution], error) {
+// SolveRecaptchaEnterprise solves a reCAPTCHA Enterprise challenge. If
+// req.MinScore is set, low-scoring solutions are solved again.
+func (c *Client) SolveRecaptchaEnterprise(ctx context.Context, req *RecaptchaEnterpriseRequest, opts ...CallOption) (*SolveResponse[RecaptchaSolution], error) {
 	internal := recaptchaEnterpriseRequestInternal{
 		TaskType:  "recaptcha_enterprise",
-		Proxy:     req.Proxy,
+		Proxy:     c.proxy(req.Proxy),
 		TargetURL: req.TargetURL,
 		SiteKey:   req.SiteKey,
 		Size:      req.Size,
@@ -59,52 +65,55 @@ func (c *Client) SolveRecaptchaEnterprise(ctx context.Context, req *RecaptchaEnt
 		Action:    req.Action,
 		Ubd:       req.Ubd,
 		Sa:        req.Sa,
+		MinScore:  req.MinScore,
 	}
 
-	var resp SolveResponse[RecaptchaSolution]
-	if err := c.doPost(ctx, "/v1/solve/recaptcha-enterprise", internal, &resp); err != nil {
-		return nil, err
-	}
-	return &resp, nil
+	return solveForScore(ctx, req.MinScore, req.MaxAttempts, func(ctx context.Context) (*SolveResponse[RecaptchaSolution], error) {
+		var resp SolveResponse[RecaptchaSolution]
+		if err := c.doPost(ctx, "/v1/solve/recaptcha-enterprise", internal, &resp, opts); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	})
 }
 
 // SolveAkamai solves an Akamai bot management challenge.
-func (c *Client) SolveAkamai(ctx context.Context, req *AkamaiRequest) (*SolveResponse[AkamaiSolution], error) {
+func (c *Client) SolveAkamai(ctx context.Context, req *AkamaiRequest, opts ...CallOption) (*SolveResponse[AkamaiSolution], error) {
 	internal := akamaiRequestInternal{
 		TaskType:    "akamai",
-		Proxy:       req.Proxy,
+		Proxy:       c.proxy(req.Proxy),
 		TargetURL:   req.TargetURL,
 		AkamaiJSURL: req.AkamaiJSURL,
 		PageFP:      req.PageFP,
 	}
 
 	var resp SolveResponse[AkamaiSolution]
-	if err := c.doPost(ctx, "/v1/solve/akamai", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/akamai", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveVercel solves a Vercel bot protection challenge.
-func (c *Client) SolveVercel(ctx context.Context, req *VercelRequest) (*SolveResponse[VercelSolution], error) {
+func (c *Client) SolveVercel(ctx context.Context, req *VercelRequest, opts ...CallOption) (*SolveResponse[VercelSolution], error) {
 	internal := vercelRequestInternal{
 		TaskType:  "vercel",
-		Proxy:     req.Proxy,
+		Proxy:     c.proxy(req.Proxy),
 		TargetURL: req.TargetURL,
 	}
 
 	var resp SolveResponse[VercelSolution]
-	if err := c.doPost(ctx, "/v1/solve/vercel", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/vercel", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveShape solves a Shape antibot challenge (v1).
-func (c *Client) SolveShape(ctx context.Context, req *ShapeRequest) (*SolveResponse[ShapeSolution], error) {
+func (c *Client) SolveShape(ctx context.Context, req *ShapeRequest, opts ...CallOption) (*SolveResponse[ShapeSolution], error) {
 	internal := shapeRequestInternal{
 		TaskType:   "shape",
-		Proxy:      req.Proxy,
+		Proxy:      c.proxy(req.Proxy),
 		TargetURL:  req.TargetURL,
 		TargetAPI:  req.TargetAPI,
 		ShapeJSURL: req.ShapeJSURL,
@@ -113,203 +122,223 @@ func (c *Client) SolveShape(ctx context.Context, req *ShapeRequest) (*SolveRespo
 	}
 
 	var resp SolveResponse[ShapeSolution]
-	if err := c.doPost(ctx, "/v1/solve/shape", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/shape", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveShapeV2 solves a Shape antibot challenge using the v2 API with TLS fingerprinting.
-func (c *Client) SolveShapeV2(ctx context.Context, req *ShapeV2Request) (*SolveResponse[ShapeV2Solution], error) {
-	// Build metadata map for the API
-	metadata := map[string]interface{}{
-		"proxy": req.Proxy,
-	}
-	if req.Pkey != "" {
-		metadata["pkey"] = req.Pkey
-	}
-	if req.ScriptURL != "" {
-		metadata["script_url"] = req.ScriptURL
-	}
-	if len(req.Request) > 0 {
-		metadata["request"] = req.Request
-	}
-	if req.Country != "" {
-		metadata["country"] = req.Country
-	}
-	if req.Timeout > 0 {
-		metadata["timeout"] = req.Timeout
+// Apply the returned headers to the protected request with
+// ShapeV2Solution.ApplyHeaders.
+func (c *Client) SolveShapeV2(ctx context.Context, req *ShapeV2Request, opts ...CallOption) (*SolveResponse[ShapeV2Solution], error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
 	}
-
-	internal := map[string]interface{}{
-		"url":      req.URL,
-		"metadata": metadata,
+	internal := shapeV2RequestInternal{
+		URL: req.URL,
+		Metadata: shapeV2Metadata{
+			Proxy:     c.proxy(req.Proxy),
+			Pkey:      req.Pkey,
+			ScriptURL: req.ScriptURL,
+			Request:   req.Request,
+			Country:   req.Country,
+			Timeout:   req.Timeout,
+		},
 	}
 
 	var resp SolveResponse[ShapeV2Solution]
-	if err := c.doPost(ctx, "/v1/solve/shape-v2", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/shape-v2", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveTurnstile solves a Cloudflare Turnstile challenge.
-func (c *Client) SolveTurnstile(ctx context.Context, req *TurnstileRequest) (*SolveResponse[TurnstileSolution], error) {
+func (c *Client) SolveTurnstile(ctx context.Context, req *TurnstileRequest, opts ...CallOption) (*SolveResponse[TurnstileSolution], error) {
 	internal := turnstileRequestInternal{
-		TaskType:  "turnstile",
-		Proxy:     req.Proxy,
-		TargetURL: req.TargetURL,
-		SiteKey:   req.SiteKey,
+		TaskType:    "turnstile",
+		Proxy:       c.proxy(req.Proxy),
+		TargetURL:   req.TargetURL,
+		SiteKey:     req.SiteKey,
+		Action:      req.Action,
+		CData:       req.CData,
+		ChlPageData: req.ChlPageData,
 	}
 
 	var resp SolveResponse[TurnstileSolution]
-	if err := c.doPost(ctx, "/v1/solve/turnstile", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/turnstile", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolvePerimeterX solves a PerimeterX Invisible challenge.
-func (c *Client) SolvePerimeterX(ctx context.Context, req *PerimeterXRequest) (*SolveResponse[PerimeterXSolution], error) {
+func (c *Client) SolvePerimeterX(ctx context.Context, req *PerimeterXRequest, opts ...CallOption) (*SolveResponse[PerimeterXSolution], error) {
 	internal := perimeterXRequestInternal{
 		TaskType:        "perimeterx_invisible",
-		Proxy:           req.Proxy,
+		Proxy:           c.proxy(req.Proxy),
 		TargetURL:       req.TargetURL,
 		PerimeterXJSURL: req.PerimeterXJSURL,
 		PxAppID:         req.PxAppID,
 	}
 
 	var resp SolveResponse[PerimeterXSolution]
-	if err := c.doPost(ctx, "/v1/solve/perimeterx-invisible", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/perimeterx-invisible", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveCloudflareWAF solves a Cloudflare WAF challenge.
-func (c *Client) SolveCloudflareWAF(ctx context.Context, req *CloudflareWAFRequest) (*SolveResponse[CloudflareWAFSolution], error) {
+func (c *Client) SolveCloudflareWAF(ctx context.Context, req *CloudflareWAFRequest, opts ...CallOption) (*SolveResponse[CloudflareWAFSolution], error) {
 	internal := cloudflareWAFRequestInternal{
-		TaskType:     "cloudflare_waf",
-		Proxy:        req.Proxy,
-		TargetURL:    req.TargetURL,
-		TargetMethod: req.TargetMethod,
+		TaskType:      "cloudflare_waf",
+		Proxy:         c.proxy(req.Proxy),
+		TargetURL:     req.TargetURL,
+		TargetMethod:  req.TargetMethod,
+		TargetBody:    req.TargetBody,
+		TargetHeaders: req.TargetHeaders,
 	}
 
 	var resp SolveResponse[CloudflareWAFSolution]
-	if err := c.doPost(ctx, "/v1/solve/cloudflare-waf", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/cloudflare-waf", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveDatadomeSlider solves a Datadome Slider CAPTCHA challenge.
-func (c *Client) SolveDatadomeSlider(ctx context.Context, req *DatadomeSliderRequest) (*SolveResponse[DatadomeSliderSolution], error) {
+func (c *Client) SolveDatadomeSlider(ctx context.Context, req *DatadomeSliderRequest, opts ...CallOption) (*SolveResponse[DatadomeSliderSolution], error) {
 	internal := datadomeSliderRequestInternal{
 		TaskType:     "datadome-slider",
-		Proxy:        req.Proxy,
+		Proxy:        c.proxy(req.Proxy),
 		TargetURL:    req.TargetURL,
 		TargetMethod: req.TargetMethod,
 	}
 
 	var resp SolveResponse[DatadomeSliderSolution]
-	if err := c.doPost(ctx, "/v1/solve/datadome-slider", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/datadome-slider", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveCaptchaFox solves a CaptchaFox challenge.
-func (c *Client) SolveCaptchaFox(ctx context.Context, req *CaptchaFoxRequest) (*SolveResponse[CaptchaFoxSolution], error) {
+func (c *Client) SolveCaptchaFox(ctx context.Context, req *CaptchaFoxRequest, opts ...CallOption) (*SolveResponse[CaptchaFoxSolution], error) {
 	internal := captchaFoxRequestInternal{
 		TaskType:  "captchafox",
-		Proxy:     req.Proxy,
+		Proxy:     c.proxy(req.Proxy),
 		TargetURL: req.TargetURL,
 		SiteKey:   req.SiteKey,
 	}
 
 	var resp SolveResponse[CaptchaFoxSolution]
-	if err := c.doPost(ctx, "/v1/solve/captchafox", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/captchafox", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveCastle solves a Castle challenge.
-func (c *Client) SolveCastle(ctx context.Context, req *CastleRequest) (*SolveResponse[CastleSolution], error) {
+func (c *Client) SolveCastle(ctx context.Context, req *CastleRequest, opts ...CallOption) (*SolveResponse[CastleSolution], error) {
 	internal := castleRequestInternal{
 		TaskType:   "castle",
-		Proxy:      req.Proxy,
+		Proxy:      c.proxy(req.Proxy),
 		TargetURL:  req.TargetURL,
 		ConfigJSON: req.ConfigJSON,
 	}
 
 	var resp SolveResponse[CastleSolution]
-	if err := c.doPost(ctx, "/v1/solve/castle", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/castle", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveReese84 solves an Incapsula Reese84 challenge.
-func (c *Client) SolveReese84(ctx context.Context, req *Reese84Request) (*SolveResponse[Reese84Solution], error) {
+func (c *Client) SolveReese84(ctx context.Context, req *Reese84Request, opts ...CallOption) (*SolveResponse[Reese84Solution], error) {
 	internal := reese84RequestInternal{
 		TaskType:     "reese84",
-		Proxy:        req.Proxy,
+		Proxy:        c.proxy(req.Proxy),
 		Reese84JsUrl: req.Reese84JsUrl,
+		TargetURL:    req.TargetURL,
 	}
 
 	var resp SolveResponse[Reese84Solution]
-	if err := c.doPost(ctx, "/v1/solve/reese84", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/reese84", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveForter solves a Forter challenge.
-func (c *Client) SolveForter(ctx context.Context, req *ForterRequest) (*SolveResponse[ForterSolution], error) {
+func (c *Client) SolveForter(ctx context.Context, req *ForterRequest, opts ...CallOption) (*SolveResponse[ForterSolution], error) {
 	internal := forterRequestInternal{
 		TaskType:    "forter",
-		Proxy:       req.Proxy,
+		Proxy:       c.proxy(req.Proxy),
 		TargetURL:   req.TargetURL,
 		ForterJsUrl: req.ForterJsUrl,
 		SiteID:      req.SiteID,
 	}
 
 	var resp SolveResponse[ForterSolution]
-	if err := c.doPost(ctx, "/v1/solve/forter", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/forter", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveFuncaptcha solves a Funcaptcha (Arkose Labs) challenge.
-func (c *Client) SolveFuncaptcha(ctx context.Context, req *FuncaptchaRequest) (*SolveResponse[FuncaptchaSolution], error) {
+func (c *Client) SolveFuncaptcha(ctx context.Context, req *FuncaptchaRequest, opts ...CallOption) (*SolveResponse[FuncaptchaSolution], error) {
 	internal := funcaptchaRequestInternal{
 		TaskType:      "funcaptcha",
-		Proxy:         req.Proxy,
+		Proxy:         c.proxy(req.Proxy),
 		TargetURL:     req.TargetURL,
 		CustomApiHost: req.CustomApiHost,
 		PublicKey:     req.PublicKey,
+		Blob:          req.Blob,
+		Language:      req.Language,
+		ExtraData:     req.ExtraData,
 	}
 
 	var resp SolveResponse[FuncaptchaSolution]
-	if err := c.doPost(ctx, "/v1/solve/funcaptcha", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/funcaptcha", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
 }
 
 // SolveSBSD solves an Akamai SBSD challenge.
-func (c *Client) SolveSBSD(ctx context.Context, req *SBSDRequest) (*SolveResponse[SBSDSolution], error) {
+func (c *Client) SolveSBSD(ctx context.Context, req *SBSDRequest, opts ...CallOption) (*SolveResponse[SBSDSolution], error) {
 	internal := sbsdRequestInternal{
 		TaskType:     "sbsd",
-		Proxy:        req.Proxy,
+		Proxy:        c.proxy(req.Proxy),
 		TargetURL:    req.TargetURL,
 		TargetMethod: req.TargetMethod,
 	}
 
 	var resp SolveResponse[SBSDSolution]
-	if err := c.doPost(ctx, "/v1/solve/sbsd", internal, &resp); err != nil {
+	if err := c.doPost(ctx, "/v1/solve/sbsd", internal, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SolvePerimeterXHold solves a PerimeterX press-and-hold challenge.
+func (c *Client) SolvePerimeterXHold(ctx context.Context, req *PerimeterXHoldRequest, opts ...CallOption) (*SolveResponse[PerimeterXHoldSolution], error) {
+	internal := perimeterXHoldRequestInternal{
+		TaskType:     "perimeterx_hold",
+		Proxy:        c.proxy(req.Proxy),
+		TargetURL:    req.TargetURL,
+		PxAppID:      req.PxAppID,
+		CaptchaJSURL: req.CaptchaJSURL,
+		UUID:         req.UUID,
+		VID:          req.VID,
+	}
+
+	var resp SolveResponse[PerimeterXHoldSolution]
+	if err := c.doPost(ctx, "/v1/solve/perimeterx-hold", internal, &resp, opts); err != nil {
 		return nil, err
 	}
 	return &resp, nil
@@ -0,0 +1,53 @@
+package gatsbie
+
+import (
+	"net/url"
+	"regexp"
+)
+
+var (
+	castlePKOption = regexp.MustCompile(`["']?pk["']?\s*:\s*["'](pk_[A-Za-z0-9]{16,})["']`)
+	castlePK       = regexp.MustCompile(`\bpk_[A-Za-z0-9]{16,}`)
+	castleWURL     = regexp.MustCompile(`["']?wUrl["']?\s*:\s*["']([^"']+)["']`)
+	castleSwURL    = regexp.MustCompile(`["']?swUrl["']?\s*:\s*["']([^"']+)["']`)
+	castleAvoid    = regexp.MustCompile(`["']?avoidCookies["']?\s*:\s*(?:!0|true)\b`)
+)
+
+// ExtractCastleConfig finds the Castle configuration in html, a page or
+// script served at baseURL: the publishable key passed to
+// Castle.configure or the Castle script URL, the worker and service worker
+// URLs, and avoidCookies. Worker URLs are resolved against baseURL. It
+// reports false if no publishable key was found.
+func ExtractCastleConfig(html []byte, baseURL string) (CastleConfigJSON, bool) {
+	var cfg CastleConfigJSON
+	if m := castlePKOption.FindSubmatch(html); m != nil {
+		cfg.PK = string(m[1])
+	} else if m := castlePK.Find(html); m != nil {
+		cfg.PK = string(m)
+	} else {
+		return CastleConfigJSON{}, false
+	}
+
+	base, _ := url.Parse(baseURL)
+	resolve := func(ref string) string {
+		ref = unescapeJSString(ref)
+		u, err := url.Parse(ref)
+		if err != nil || base == nil {
+			return ref
+		}
+		return base.ResolveReference(u).String()
+	}
+	if m := castleWURL.FindSubmatch(html); m != nil {
+		cfg.WUrl = resolve(string(m[1]))
+	}
+	if m := castleSwURL.FindSubmatch(html); m != nil {
+		cfg.SwUrl = resolve(string(m[1]))
+	}
+	cfg.AvoidCookies = castleAvoid.Match(html)
+	return cfg, true
+}
+
+// Request returns a CastleRequest for the configuration.
+func (c CastleConfigJSON) Request(proxy, targetURL string) *CastleRequest {
+	return &CastleRequest{Proxy: proxy, TargetURL: targetURL, ConfigJSON: c}
+}
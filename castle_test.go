@@ -0,0 +1,68 @@
+package gatsbie_test
+
+import (
+	"context"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestExtractCastleConfig(t *testing.T) {
+	tests := []struct {
+		fixture string
+		baseURL string
+		want    gatsbie.CastleConfigJSON
+	}{
+		{
+			fixture: "signup.html",
+			baseURL: "https://www.example.com/signup",
+			want: gatsbie.CastleConfigJSON{
+				PK:    "pk_Q6vWmX3hT9bLr2NzKf8YcJ5d",
+				WUrl:  "https://www.example.com/castle/worker.js",
+				SwUrl: "https://www.example.com/castle/sw.js",
+			},
+		},
+		{
+			fixture: "bundle.js",
+			baseURL: "https://www.example.com/static/js/main.js",
+			want: gatsbie.CastleConfigJSON{
+				AvoidCookies: true,
+				PK:           "pk_H2kLp9sVq4XwZr7TbN1mCf6g",
+				WUrl:         "https://assets.example.com/static/c/w.js",
+				SwUrl:        "https://www.example.com/sw-castle.js",
+			},
+		},
+		{
+			fixture: "script_tag.html",
+			baseURL: "https://www.example.com/login",
+			want:    gatsbie.CastleConfigJSON{PK: "pk_R8nDc2Wq5ZxLt7KvJm3Ya9Pe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, ok := gatsbie.ExtractCastleConfig(readFixture(t, "castle", tt.fixture), tt.baseURL)
+			if !ok || got != tt.want {
+				t.Errorf("ExtractCastleConfig = %+v, %v\nwant %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if got, ok := gatsbie.ExtractCastleConfig(readFixture(t, "castle", "stripe.html"), "https://www.example.com/"); ok {
+		t.Errorf("ExtractCastleConfig took a Stripe key: %+v", got)
+	}
+}
+
+func TestCastleConfigRequest(t *testing.T) {
+	cfg, ok := gatsbie.ExtractCastleConfig(readFixture(t, "castle", "bundle.js"), "https://www.example.com/")
+	if !ok {
+		t.Fatal("no config found")
+	}
+	srv := gatsbietest.NewServer(t)
+	if _, err := srv.Client().SolveCastle(context.Background(), cfg.Request(testProxy, "https://www.example.com/signup")); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.pk", "pk_H2kLp9sVq4XwZr7TbN1mCf6g")
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.wUrl", "https://assets.example.com/static/c/w.js")
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.avoidCookies", true)
+}
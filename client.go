@@ -4,124 +4,517 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/endpoint"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/httpbody"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/ratelimit"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/retry"
 )
 
 const (
-	defaultBaseURL = "https://api2.gatsbie.io"
-	defaultTimeout = 120 * time.Second
+	defaultBaseURL      = "https://api2.gatsbie.io"
+	defaultTimeout      = 120 * time.Second
+	defaultRetryBackoff = time.Second
+
+	// errorBodyLimit bounds how much of an error response is read, and
+	// errorSnippetSize how much of a non-JSON one is quoted in the error.
+	errorBodyLimit   = 64 << 10
+	errorSnippetSize = 512
 )
 
+// ErrResponseTooLarge is returned, wrapped, when a response body exceeds
+// the limit set with WithMaxResponseSize.
+var ErrResponseTooLarge = httpbody.ErrTooLarge
+
 // Client is the Gatsbie API client.
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	httpClient   *http.Client
+	timeout      time.Duration
+	metricsHook  MetricsHook
+	ledger       Ledger
+	ledgerErr    LedgerErrorHook
+	maxRetries   int
+	retryBackoff time.Duration
+	defaultProxy string
+	maxBodySize  int64
+	endpoints    *endpoint.Pool
+	keys         *keyPool
+	limiter      *ratelimit.Limiter
+}
+
+// options collects the settings made by Option functions. They are applied
+// together in NewClient, so the order options are passed in does not
+// matter.
+type options struct {
+	baseURLs            []string
+	apiKeys             []WeightedAPIKey
+	weightedKeys        bool
+	keyCooldown         time.Duration
+	httpClient          *http.Client
+	timeout             time.Duration
+	healthCheckInterval time.Duration
+	metricsHook         MetricsHook
+	ledger              Ledger
+	ledgerErr           LedgerErrorHook
+	maxRetries          int
+	retryBackoff        time.Duration
+	rateLimit           float64
+	rateBurst           int
+	defaultProxy        string
+	maxBodySize         int64
 }
 
 // Option is a functional option for configuring the Client.
-type Option func(*Client)
+type Option func(*options)
 
 // WithBaseURL sets a custom base URL for the API.
 func WithBaseURL(url string) Option {
-	return func(c *Client) {
-		c.baseURL = url
+	return func(o *options) {
+		o.baseURLs = []string{url}
+	}
+}
+
+// WithEndpoints sets several base URLs for the API. Requests are routed to
+// the healthiest, lowest-latency endpoint and fail over to the next one on
+// connection errors and 5xx responses. With more than one endpoint the
+// client probes them in the background, and latency is measured by those
+// probes alone; call Close to stop probing.
+func WithEndpoints(urls ...string) Option {
+	return func(o *options) {
+		if len(urls) > 0 {
+			o.baseURLs = append([]string(nil), urls...)
+		}
+	}
+}
+
+// WithHealthCheckInterval sets how often endpoints configured with
+// WithEndpoints are probed. The default is 30 seconds.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.healthCheckInterval = interval
+	}
+}
+
+// WithAPIKeys sets a pool of API keys that requests are spread across in
+// round-robin order, replacing the key passed to NewClient. A key that
+// returns INSUFFICIENT_CREDITS is taken out of rotation for the key
+// cooldown and a key that returns AUTH_FAILED is disabled permanently; in
+// both cases the request is retried with the next available key.
+func WithAPIKeys(keys ...string) Option {
+	return func(o *options) {
+		if len(keys) == 0 {
+			return
+		}
+		o.apiKeys = o.apiKeys[:0]
+		for _, k := range keys {
+			o.apiKeys = append(o.apiKeys, WeightedAPIKey{Key: k, Weight: 1})
+		}
+		o.weightedKeys = false
+	}
+}
+
+// WithWeightedAPIKeys is like WithAPIKeys but selects keys in proportion to
+// their weight.
+func WithWeightedAPIKeys(keys ...WeightedAPIKey) Option {
+	return func(o *options) {
+		if len(keys) == 0 {
+			return
+		}
+		o.apiKeys = append([]WeightedAPIKey(nil), keys...)
+		o.weightedKeys = true
 	}
 }
 
-// WithHTTPClient sets a custom HTTP client.
+// WithKeyCooldown sets how long a key that ran out of credits is kept out
+// of rotation. The default is 10 minutes.
+func WithKeyCooldown(cooldown time.Duration) Option {
+	return func(o *options) {
+		o.keyCooldown = cooldown
+	}
+}
+
+// WithHTTPClient sets a custom HTTP client. The client is copied, not
+// modified, so it can be shared with other code. Its Timeout is used
+// unless WithTimeout is also given.
 func WithHTTPClient(client *http.Client) Option {
-	return func(c *Client) {
-		c.httpClient = client
+	return func(o *options) {
+		o.httpClient = client
 	}
 }
 
-// WithTimeout sets the HTTP client timeout.
+// WithTimeout sets the timeout for each HTTP request. It can be overridden
+// per call with WithCallTimeout.
 func WithTimeout(timeout time.Duration) Option {
-	return func(c *Client) {
-		c.httpClient.Timeout = timeout
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry retries requests that failed on every endpoint with a
+// connection error or 5xx response up to maxRetries times, waiting backoff
+// before the first retry and doubling it each time. Solve retries reuse
+// the request's idempotency key, so they are not charged twice.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.maxRetries = maxRetries
+		o.retryBackoff = backoff
+	}
+}
+
+// WithRateLimit limits the client to requestsPerSecond requests, allowing
+// bursts of up to burst requests. Calls block until they may proceed or
+// their context is done.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.rateLimit = requestsPerSecond
+		o.rateBurst = burst
+	}
+}
+
+// WithDefaultProxy sets the proxy used by solve requests that do not set
+// one themselves.
+func WithDefaultProxy(proxy string) Option {
+	return func(o *options) {
+		o.defaultProxy = proxy
+	}
+}
+
+// WithMaxResponseSize limits the size of response bodies. Larger
+// responses fail with ErrResponseTooLarge. The default is 10 MiB.
+func WithMaxResponseSize(bytes int64) Option {
+	return func(o *options) {
+		o.maxBodySize = bytes
 	}
 }
 
 // NewClient creates a new Gatsbie API client.
 // The apiKey should be a valid Gatsbie API key starting with "gats_".
 func NewClient(apiKey string, opts ...Option) *Client {
-	c := &Client{
-		baseURL: defaultBaseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
+	o := options{
+		baseURLs:     []string{defaultBaseURL},
+		apiKeys:      []WeightedAPIKey{{Key: apiKey, Weight: 1}},
+		retryBackoff: defaultRetryBackoff,
+		maxBodySize:  httpbody.DefaultMaxSize,
 	}
-
 	for _, opt := range opts {
-		opt(c)
+		opt(&o)
 	}
 
+	// Work on a copy of the caller's client so that neither WithTimeout
+	// nor anything else changes a client the caller may share. Timeouts
+	// are enforced per request through the context instead, which lets
+	// WithCallTimeout override them in either direction.
+	httpClient := &http.Client{}
+	timeout := defaultTimeout
+	if o.httpClient != nil {
+		clone := *o.httpClient
+		httpClient = &clone
+		timeout = o.httpClient.Timeout
+	}
+	httpClient.Timeout = 0
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+
+	c := &Client{
+		httpClient:   httpClient,
+		timeout:      timeout,
+		metricsHook:  o.metricsHook,
+		ledger:       o.ledger,
+		ledgerErr:    o.ledgerErr,
+		maxRetries:   o.maxRetries,
+		retryBackoff: o.retryBackoff,
+		defaultProxy: o.defaultProxy,
+		maxBodySize:  o.maxBodySize,
+		keys:         newKeyPool(o.apiKeys, o.weightedKeys, o.keyCooldown),
+		limiter:      ratelimit.New(o.rateLimit, o.rateBurst),
+		endpoints:    endpoint.New(o.baseURLs),
+	}
+	c.endpoints.Start(o.healthCheckInterval, defaultTimeout, c.probe)
+
 	return c
 }
 
-// do performs an HTTP request with authentication.
-func (c *Client) do(ctx context.Context, method, path string, body any, result any) error {
-	var reqBody io.Reader
+// Close stops background endpoint health checks. The client must not be
+// used after Close.
+func (c *Client) Close() error {
+	c.endpoints.Close()
+	return nil
+}
+
+// EndpointStatus describes the observed health of a configured endpoint.
+type EndpointStatus = endpoint.Status
+
+// Endpoints returns the current health of every configured endpoint.
+func (c *Client) Endpoints() []EndpointStatus {
+	return c.endpoints.Statuses()
+}
+
+// probe checks the health of a single endpoint. Probes are not reported to
+// the metrics hook, and use a key that has not been disabled, or none.
+func (c *Client) probe(ctx context.Context, baseURL string) error {
+	var resp HealthResponse
+	r := &request{method: http.MethodGet, path: "/health", result: &resp, probe: true}
+	if _, err := c.doOnce(ctx, baseURL, c.keys.live(), r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// request is a single API call as it moves through key rotation, endpoint
+// failover and retries.
+type request struct {
+	method  string
+	path    string
+	body    []byte
+	result  any
+	idemKey string
+	timeout time.Duration // per attempt; zero means no limit
+	probe   bool          // a health probe, not reported to the metrics hook
+}
+
+// do performs an HTTP request with authentication, rotating API keys on
+// credit and auth failures and failing over between endpoints on
+// connection errors and 5xx responses.
+func (c *Client) do(ctx context.Context, method, path string, body any, result any, opts []CallOption) error {
+	r := &request{method: method, path: path, result: result, timeout: c.timeout}
 	if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("gatsbie: failed to marshal request: %w", err)
 		}
-		reqBody = bytes.NewReader(data)
+		r.body = data
+	}
+
+	// A call timeout bounds the whole call, retries included, and replaces
+	// the client's per-request timeout.
+	co := newCallOptions(opts)
+	if co.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, co.timeout)
+		defer cancel()
+		r.timeout = 0
+	}
+
+	// Solve requests carry an idempotency key that is shared by every
+	// attempt below, so a retry after a dropped response is not charged
+	// twice.
+	if method == http.MethodPost {
+		if key, ok := IdempotencyKeyFromContext(ctx); ok {
+			r.idemKey = key
+		} else {
+			r.idemKey = newIdempotencyKey()
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	start := time.Now()
+	err := c.doKeys(ctx, r)
+	c.record(ctx, r, start, result, err)
+	if r.idemKey == "" {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("gatsbie: failed to create request: %w", err)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.IdempotencyKey = r.idemKey
+			return err
+		}
+		return &RequestError{IdempotencyKey: r.idemKey, Err: err}
 	}
+	if res, ok := result.(idempotent); ok {
+		res.setIdempotencyKey(r.idemKey)
+	}
+	return nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+// doKeys performs a request, rotating API keys on credit and auth failures.
+func (c *Client) doKeys(ctx context.Context, r *request) error {
+	var lastErr error
+	for {
+		apiKey, err := c.keys.pick()
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
 
-	resp, err := c.httpClient.Do(req)
+		err = c.doEndpoints(ctx, apiKey, r)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+		switch {
+		case apiErr.IsInsufficientCredits():
+			c.keys.coolDown(apiKey)
+		case apiErr.IsAuthError():
+			c.keys.disable(apiKey)
+		default:
+			return err
+		}
+		if c.keys.size() == 1 {
+			return err
+		}
+		lastErr = err
+	}
+}
+
+// doEndpoints performs a request with apiKey, trying each endpoint in turn
+// and retrying the whole set with backoff if every endpoint failed.
+func (c *Client) doEndpoints(ctx context.Context, apiKey string, r *request) error {
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := retry.Sleep(ctx, retry.Backoff(c.retryBackoff, attempt)); err != nil {
+				return lastErr
+			}
+		}
+
+		for _, baseURL := range c.endpoints.Ordered() {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("gatsbie: rate limit: %w", err)
+			}
+
+			failover, err := c.doOnce(ctx, baseURL, apiKey, r)
+			if !failover {
+				if err == nil {
+					c.endpoints.MarkHealthy(baseURL)
+				}
+				return err
+			}
+			c.endpoints.MarkFailure(baseURL, err)
+			lastErr = err
+			if ctx.Err() != nil {
+				return lastErr
+			}
+		}
+	}
+	return lastErr
+}
+
+// doOnce performs a single request against baseURL. It reports whether the
+// failure is one that should be retried against another endpoint.
+func (c *Client) doOnce(ctx context.Context, baseURL, apiKey string, r *request) (failover bool, err error) {
+	m := RequestMetrics{
+		Method:   r.method,
+		Path:     r.path,
+		Endpoint: baseURL,
+		APIKeyID: maskAPIKey(apiKey),
+	}
+	start := time.Now()
+	defer func() {
+		if c.metricsHook == nil || r.probe {
+			return
+		}
+		m.Duration = time.Since(start)
+		m.Err = err
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			m.ErrorCode = apiErr.Code
+		}
+		c.metricsHook(m)
+	}()
+
+	// The per-request timeout lives on its own context so that hitting it
+	// still counts as an endpoint failure rather than the caller giving up.
+	attemptCtx := ctx
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if r.body != nil {
+		reqBody = bytes.NewReader(r.body)
+	}
+
+	req, err := http.NewRequestWithContext(attemptCtx, r.method, baseURL+r.path, reqBody)
 	if err != nil {
-		return fmt.Errorf("gatsbie: request failed: %w", err)
+		return false, fmt.Errorf("gatsbie: failed to create request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if r.idemKey != "" {
+		req.Header.Set(idempotencyHeader, r.idemKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("gatsbie: failed to read response: %w", err)
+		return isConnectionError(ctx, err), fmt.Errorf("gatsbie: request failed: %w", err)
 	}
+	defer resp.Body.Close()
+	m.StatusCode = resp.StatusCode
 
 	// Check for error responses
 	if resp.StatusCode >= 400 {
+		failover := resp.StatusCode >= 500
+		respBody, truncated, err := httpbody.ReadPrefix(resp.Body, min(errorBodyLimit, c.maxBodySize))
+		if err != nil {
+			return isConnectionError(ctx, err), fmt.Errorf("gatsbie: failed to read response: %w", err)
+		}
 		var errResp errorResponse
 		if err := json.Unmarshal(respBody, &errResp); err != nil {
-			return fmt.Errorf("gatsbie: unexpected error response (status %d): %s", resp.StatusCode, string(respBody))
+			return failover, fmt.Errorf("gatsbie: unexpected error response (status %d): %s",
+				resp.StatusCode, httpbody.Snippet(respBody, errorSnippetSize, truncated))
 		}
 		if errResp.Error != nil {
 			errResp.Error.HTTPStatus = resp.StatusCode
-			return errResp.Error
+			return failover, errResp.Error
 		}
-		return fmt.Errorf("gatsbie: unexpected error (status %d)", resp.StatusCode)
+		return failover, fmt.Errorf("gatsbie: unexpected error (status %d)", resp.StatusCode)
 	}
 
-	if result != nil {
-		if err := json.Unmarshal(respBody, result); err != nil {
-			return fmt.Errorf("gatsbie: failed to unmarshal response: %w", err)
+	if r.result != nil {
+		body := httpbody.NewReader(resp.Body, c.maxBodySize)
+		if err := json.NewDecoder(body).Decode(r.result); err != nil {
+			switch readErr := body.Err(); {
+			case errors.Is(readErr, httpbody.ErrTooLarge):
+				return false, fmt.Errorf("gatsbie: failed to read response: %w (limit %d bytes)", readErr, c.maxBodySize)
+			case readErr != nil:
+				return isConnectionError(ctx, readErr), fmt.Errorf("gatsbie: failed to read response: %w", readErr)
+			}
+			return false, fmt.Errorf("gatsbie: failed to unmarshal response: %w", err)
+		}
+		if res, ok := r.result.(received); ok {
+			res.setReceivedAt(time.Now())
+		}
+		if res, ok := r.result.(billed); ok {
+			m.Cost = res.billedCost()
 		}
 	}
 
-	return nil
+	return false, nil
+}
+
+// isConnectionError reports whether err is a transport failure rather than
+// the caller giving up on the request.
+func isConnectionError(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil
+}
+
+// proxy returns p, or the client's default proxy if p is empty.
+func (c *Client) proxy(p string) string {
+	if p == "" {
+		return c.defaultProxy
+	}
+	return p
 }
 
 // doGet performs a GET request.
-func (c *Client) doGet(ctx context.Context, path string, result any) error {
-	return c.do(ctx, http.MethodGet, path, nil, result)
+func (c *Client) doGet(ctx context.Context, path string, result any, opts []CallOption) error {
+	return c.do(ctx, http.MethodGet, path, nil, result, opts)
 }
 
 // doPost performs a POST request.
-func (c *Client) doPost(ctx context.Context, path string, body any, result any) error {
-	return c.do(ctx, http.MethodPost, path, body, result)
+func (c *Client) doPost(ctx context.Context, path string, body any, result any, opts []CallOption) error {
+	return c.do(ctx, http.MethodPost, path, body, result, opts)
 }
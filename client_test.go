@@ -0,0 +1,317 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestRetryReusesIdempotencyKey(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile,
+		gatsbietest.Error(gatsbie.ErrCodeUpstreamError, "try again"),
+		gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)),
+	)
+
+	resp, err := srv.Client(gatsbie.WithRetry(1, time.Millisecond)).SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := srv.Requests(gatsbietest.TaskTurnstile)
+	if len(requests) != 2 {
+		t.Fatalf("server saw %d requests, want 2", len(requests))
+	}
+	first := requests[0].Header.Get("Idempotency-Key")
+	if first == "" {
+		t.Fatal("no Idempotency-Key header")
+	}
+	if second := requests[1].Header.Get("Idempotency-Key"); second != first {
+		t.Errorf("retry used key %q, first attempt used %q", second, first)
+	}
+	if resp.IdempotencyKey != first {
+		t.Errorf("resp.IdempotencyKey = %q, want %q", resp.IdempotencyKey, first)
+	}
+}
+
+func TestIdempotencyKeyFromContext(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no"))
+
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "order-42")
+	_, err := srv.Client().SolveTurnstile(ctx, &gatsbie.TurnstileRequest{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got := srv.LastRequest(gatsbietest.TaskTurnstile).Header.Get("Idempotency-Key"); got != "order-42" {
+		t.Errorf("Idempotency-Key = %q, want order-42", got)
+	}
+	if key := gatsbie.IdempotencyKeyFromError(err); key != "order-42" {
+		t.Errorf("IdempotencyKeyFromError = %q, want order-42", key)
+	}
+}
+
+func TestHealthHasNoIdempotencyKey(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	if _, err := srv.Client().Health(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.LastRequest(gatsbietest.TaskHealth).Header.Get("Idempotency-Key"); got != "" {
+		t.Errorf("Idempotency-Key = %q on a GET", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	tests := []struct {
+		code string
+	}{
+		{gatsbie.ErrCodeInsufficientCredits},
+		{gatsbie.ErrCodeAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			srv.RequireAPIKey("")
+			srv.Handle(gatsbietest.TaskTurnstile,
+				gatsbietest.Error(tt.code, "key rejected"),
+				gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)),
+			)
+			client := srv.Client(gatsbie.WithAPIKeys("gats_key_one", "gats_key_two"))
+
+			if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+				t.Fatal(err)
+			}
+			// The rejected key is out of rotation, so the next call goes
+			// to the other key as well.
+			if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+				t.Fatal(err)
+			}
+
+			requests := srv.Requests(gatsbietest.TaskTurnstile)
+			if len(requests) != 3 {
+				t.Fatalf("server saw %d requests, want 3", len(requests))
+			}
+			want := []string{"gats_key_one", "gats_key_two", "gats_key_two"}
+			for i, r := range requests {
+				if got := r.Header.Get("Authorization"); got != "Bearer "+want[i] {
+					t.Errorf("request %d Authorization = %q, want %s", i, got, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSingleKeyStaysInRotation(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile,
+		gatsbietest.Error(gatsbie.ErrCodeInsufficientCredits, "top up"),
+		gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)),
+	)
+	client := srv.Client()
+
+	_, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInsufficientCredits() {
+		t.Fatalf("err = %v, want insufficient credits", err)
+	}
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Errorf("second call: %v", err)
+	}
+}
+
+func TestEndpointFailover(t *testing.T) {
+	down := gatsbietest.NewServer(t)
+	down.Handle(gatsbietest.TaskTurnstile, gatsbietest.Error(gatsbie.ErrCodeInternalError, "down"))
+	up := gatsbietest.NewServer(t)
+
+	client := gatsbie.NewClient(gatsbietest.TestAPIKey,
+		gatsbie.WithEndpoints(down.URL, up.URL),
+		gatsbie.WithHealthCheckInterval(time.Hour),
+	)
+	defer client.Close()
+
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(down.Requests(gatsbietest.TaskTurnstile)); n != 1 {
+		t.Errorf("failing endpoint saw %d requests, want 1", n)
+	}
+	if n := len(up.Requests(gatsbietest.TaskTurnstile)); n != 1 {
+		t.Errorf("healthy endpoint saw %d requests, want 1", n)
+	}
+}
+
+func TestClientErrorsDoNotFailOver(t *testing.T) {
+	first := gatsbietest.NewServer(t)
+	first.Handle(gatsbietest.TaskTurnstile, gatsbietest.Error(gatsbie.ErrCodeInvalidRequest, "bad"))
+	second := gatsbietest.NewServer(t)
+
+	client := gatsbie.NewClient(gatsbietest.TestAPIKey,
+		gatsbie.WithEndpoints(first.URL, second.URL),
+		gatsbie.WithHealthCheckInterval(time.Hour),
+	)
+	defer client.Close()
+
+	_, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want invalid request", err)
+	}
+	if n := len(second.Requests(gatsbietest.TaskTurnstile)); n != 0 {
+		t.Errorf("second endpoint saw %d requests, want 0", n)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)).WithDelay(time.Second))
+
+	start := time.Now()
+	_, err := srv.Client(gatsbie.WithRetry(3, time.Millisecond)).SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{},
+		gatsbie.WithCallTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("call took %v, want it bounded by the call timeout", elapsed)
+	}
+}
+
+func TestDefaultProxy(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	client := srv.Client(gatsbie.WithDefaultProxy(testProxy))
+
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskTurnstile, "proxy", testProxy)
+
+	const other = "http://other:8080"
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{Proxy: other}); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskTurnstile, "proxy", other)
+}
+
+func TestMetricsHook(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)).WithCost(0.005))
+
+	var (
+		mu  sync.Mutex
+		got []gatsbie.RequestMetrics
+	)
+	client := srv.Client(gatsbie.WithMetricsHook(func(m gatsbie.RequestMetrics) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, m)
+	}))
+
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 {
+		t.Fatalf("hook called %d times, want 1", len(got))
+	}
+	m := got[0]
+	if m.Method != http.MethodPost || m.Path != gatsbietest.TaskTurnstile.Path() || m.Endpoint != srv.URL {
+		t.Errorf("metrics = %+v", m)
+	}
+	if m.StatusCode != http.StatusOK || m.Cost != 0.005 || m.Err != nil {
+		t.Errorf("metrics = %+v", m)
+	}
+	if m.APIKeyID == "" || m.APIKeyID == gatsbietest.TestAPIKey {
+		t.Errorf("APIKeyID = %q, want a masked key", m.APIKeyID)
+	}
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCallsDoNotSkewEndpointLatency(t *testing.T) {
+	a := gatsbietest.NewServer(t)
+	b := gatsbietest.NewServer(t)
+	for _, srv := range []*gatsbietest.Server{a, b} {
+		srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)).WithDelay(100*time.Millisecond))
+	}
+	client := gatsbie.NewClient(gatsbietest.TestAPIKey,
+		gatsbie.WithEndpoints(a.URL, b.URL),
+		gatsbie.WithHealthCheckInterval(time.Hour),
+	)
+	defer client.Close()
+
+	waitFor(t, "the first probes", func() bool {
+		for _, s := range client.Endpoints() {
+			if s.Latency == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	before := client.Endpoints()
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range client.Endpoints() {
+		if s.Latency != before[i].Latency || !s.Healthy {
+			t.Errorf("%s: latency %v -> %v, healthy %v; a slow solve changed the probe latency", s.URL, before[i].Latency, s.Latency, s.Healthy)
+		}
+	}
+}
+
+func TestProbesSkipMetricsAndDisabledKeys(t *testing.T) {
+	a := gatsbietest.NewServer(t)
+	b := gatsbietest.NewServer(t)
+	a.RequireAPIKey("gats_key_two")
+	b.RequireAPIKey("gats_key_two")
+
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	client := gatsbie.NewClient("",
+		gatsbie.WithAPIKeys("gats_key_one", "gats_key_two"),
+		gatsbie.WithEndpoints(a.URL, b.URL),
+		gatsbie.WithHealthCheckInterval(10*time.Millisecond),
+		gatsbie.WithMetricsHook(func(m gatsbie.RequestMetrics) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, m.Path)
+		}),
+	)
+	defer client.Close()
+
+	// The first key is rejected and disabled.
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a probe with the live key", func() bool {
+		probes := a.Requests(gatsbietest.TaskHealth)
+		return len(probes) > 0 && probes[len(probes)-1].Header.Get("Authorization") == "Bearer gats_key_two"
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{gatsbietest.TaskTurnstile.Path(), gatsbietest.TaskTurnstile.Path()}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("metrics hook saw %q, want only the two solve attempts", paths)
+	}
+}
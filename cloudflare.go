@@ -0,0 +1,144 @@
+package gatsbie
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/httpbody"
+)
+
+// AddTo adds the cookies to req, including those in Extra.
+func (c CloudflareWAFCookies) AddTo(req *http.Request) {
+	if c.CfClearance != "" {
+		req.AddCookie(&http.Cookie{Name: "cf_clearance", Value: c.CfClearance})
+	}
+	if c.CfBm != "" {
+		req.AddCookie(&http.Cookie{Name: "__cf_bm", Value: c.CfBm})
+	}
+	for name, v := range c.Extra {
+		if s, ok := v.(string); ok && s != "" {
+			req.AddCookie(&http.Cookie{Name: name, Value: s})
+		}
+	}
+}
+
+// splitClearance moves the Expires and Max-Age attributes of a
+// cf_clearance Set-Cookie line, such as "abc; Expires=...; Max-Age=1800",
+// into ClearanceExpires and ClearanceMaxAge, leaving the value.
+func (c *CloudflareWAFCookies) splitClearance() {
+	if !strings.Contains(c.CfClearance, ";") {
+		return
+	}
+	line := c.CfClearance
+	if !strings.HasPrefix(line, "cf_clearance=") {
+		line = "cf_clearance=" + line
+	}
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": {line}}}).Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	c.CfClearance = cookies[0].Value
+	c.ClearanceExpires = cookies[0].Expires
+	c.ClearanceMaxAge = cookies[0].MaxAge
+}
+
+// clearanceLine returns cf_clearance as a Set-Cookie line carrying its
+// expiry attributes, or just the value if it has none.
+func (c CloudflareWAFCookies) clearanceLine() string {
+	if c.ClearanceExpires.IsZero() && c.ClearanceMaxAge == 0 {
+		return c.CfClearance
+	}
+	return (&http.Cookie{
+		Name:    "cf_clearance",
+		Value:   c.CfClearance,
+		Expires: c.ClearanceExpires,
+		MaxAge:  c.ClearanceMaxAge,
+	}).String()
+}
+
+// cloudflareChallengePage matches the markers of Cloudflare's "Just a
+// moment..." interstitial. The /cdn-cgi/challenge-platform/ script is not
+// one of them: Cloudflare also adds it to ordinary pages for bot
+// detection.
+var cloudflareChallengePage = regexp.MustCompile(`<title>Just a moment\.\.\.</title>|window\._cf_chl_opt`)
+
+// IsCloudflareChallenge reports whether a response with status, header and
+// body is a Cloudflare "Just a moment..." challenge: either it carries
+// Cf-Mitigated: challenge, or it is a 403 or 503 whose body is the
+// interstitial. header may be nil.
+func IsCloudflareChallenge(status int, header http.Header, body []byte) bool {
+	if header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+	if status != http.StatusForbidden && status != http.StatusServiceUnavailable {
+		return false
+	}
+	return cloudflareChallengePage.Match(body)
+}
+
+// skippedTargetHeaders are request headers the solver's browser sets
+// itself, so they are not copied into TargetHeaders.
+var skippedTargetHeaders = map[string]bool{
+	"Accept-Encoding": true,
+	"Connection":      true,
+	"Content-Length":  true,
+	"Host":            true,
+	"User-Agent":      true,
+}
+
+// NewCloudflareWAFRequest builds a CloudflareWAFRequest from resp, a
+// Cloudflare "Just a moment..." challenge response. The target URL,
+// method, headers and body are taken from the request that was
+// challenged; the body can only be recovered if the request has GetBody,
+// as requests built by http.NewRequest from a bytes or strings reader do.
+// resp's body is read and replaced.
+//
+// The returned error is an *APIError with code INVALID_REQUEST if resp is
+// not a Cloudflare challenge.
+func NewCloudflareWAFRequest(resp *http.Response, proxy string) (*CloudflareWAFRequest, error) {
+	if resp == nil || resp.Body == nil || resp.Request == nil {
+		return nil, invalidRequest("cloudflare: no challenged response")
+	}
+	data, err := readBlockedBody(resp, httpbody.DefaultMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("cloudflare: %w", err)
+	}
+	if !IsCloudflareChallenge(resp.StatusCode, resp.Header, data) {
+		return nil, invalidRequest("cloudflare: response is not a challenge (status %d)", resp.StatusCode)
+	}
+
+	r := resp.Request
+	req := &CloudflareWAFRequest{
+		Proxy:        proxy,
+		TargetURL:    r.URL.String(),
+		TargetMethod: r.Method,
+	}
+	if req.TargetMethod == "" {
+		req.TargetMethod = http.MethodGet
+	}
+	for name, values := range r.Header {
+		if skippedTargetHeaders[http.CanonicalHeaderKey(name)] || len(values) == 0 {
+			continue
+		}
+		if req.TargetHeaders == nil {
+			req.TargetHeaders = map[string]string{}
+		}
+		req.TargetHeaders[name] = strings.Join(values, ", ")
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("cloudflare: failed to read challenged request body: %w", err)
+		}
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("cloudflare: failed to read challenged request body: %w", err)
+		}
+		req.TargetBody = string(data)
+	}
+	return req, nil
+}
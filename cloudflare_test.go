@@ -0,0 +1,161 @@
+package gatsbie_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func challengedResponse(t *testing.T, req *http.Request, header http.Header, body []byte) *http.Response {
+	t.Helper()
+	return &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestNewCloudflareWAFRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://www.example.com/api/cart", strings.NewReader(`{"sku":"123","qty":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+
+	resp := challengedResponse(t, req, http.Header{"Server": {"cloudflare"}}, readFixture(t, "cloudflare", "just_a_moment.html"))
+	got, err := gatsbie.NewCloudflareWAFRequest(resp, testProxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TargetURL != "https://www.example.com/api/cart" || got.TargetMethod != "POST" || got.Proxy != testProxy {
+		t.Errorf("request = %+v", got)
+	}
+	if got.TargetBody != `{"sku":"123","qty":1}` {
+		t.Errorf("TargetBody = %q", got.TargetBody)
+	}
+	want := map[string]string{"Content-Type": "application/json", "X-Requested-With": "XMLHttpRequest"}
+	if len(got.TargetHeaders) != len(want) {
+		t.Errorf("TargetHeaders = %v, want %v", got.TargetHeaders, want)
+	}
+	for k, v := range want {
+		if got.TargetHeaders[k] != v {
+			t.Errorf("TargetHeaders[%s] = %q, want %q", k, got.TargetHeaders[k], v)
+		}
+	}
+
+	srv := gatsbietest.NewServer(t)
+	if _, err := srv.Client().SolveCloudflareWAF(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskCloudflareWAF, "target_body", `{"sku":"123","qty":1}`)
+	srv.ExpectField(gatsbietest.TaskCloudflareWAF, "target_headers.Content-Type", "application/json")
+}
+
+func TestNewCloudflareWAFRequestMitigatedHeader(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+	resp := challengedResponse(t, req, http.Header{"Cf-Mitigated": {"challenge"}}, []byte("<html></html>"))
+	got, err := gatsbie.NewCloudflareWAFRequest(resp, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TargetMethod != "GET" || got.TargetBody != "" || got.TargetHeaders != nil {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestNewCloudflareWAFRequestNotChallenge(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+	_, err := gatsbie.NewCloudflareWAFRequest(challengedResponse(t, req, nil, []byte("<html>Forbidden</html>")), "")
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want INVALID_REQUEST", err)
+	}
+}
+
+func TestIsCloudflareChallenge(t *testing.T) {
+	challenge := readFixture(t, "cloudflare", "just_a_moment.html")
+	normal := readFixture(t, "cloudflare", "normal.html")
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   []byte
+		want   bool
+	}{
+		{"interstitial 403", http.StatusForbidden, nil, challenge, true},
+		{"interstitial 503", http.StatusServiceUnavailable, nil, challenge, true},
+		{"interstitial 200", http.StatusOK, nil, challenge, false},
+		{"mitigated header", http.StatusOK, http.Header{"Cf-Mitigated": {"challenge"}}, nil, true},
+		{"normal page with challenge-platform script", http.StatusOK, http.Header{"Server": {"cloudflare"}}, normal, false},
+		{"blocked normal page", http.StatusForbidden, nil, normal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gatsbie.IsCloudflareChallenge(tt.status, tt.header, tt.body); got != tt.want {
+				t.Errorf("IsCloudflareChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudflareWAFCookies(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskCloudflareWAF, gatsbietest.Response{
+		Body: `{"success":true,"taskId":"t","service":"cloudflare-waf","solution":{"cookies":{"cf_clearance":"clear","__cf_bm":"bm","_cfuvid":"uv"},"ua":"ua"},"cost":0.002,"solveTime":1}`,
+	})
+	resp, err := srv.Client().SolveCloudflareWAF(context.Background(), &gatsbie.CloudflareWAFRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := resp.Solution.Cookies
+	if c.CfClearance != "clear" || c.CfBm != "bm" || c.Extra["_cfuvid"] != "uv" {
+		t.Errorf("Cookies = %+v", c)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+	c.AddTo(req)
+	for name, want := range map[string]string{"cf_clearance": "clear", "__cf_bm": "bm", "_cfuvid": "uv"} {
+		if got, err := req.Cookie(name); err != nil || got.Value != want {
+			t.Errorf("cookie %s = %v, %v", name, got, err)
+		}
+	}
+}
+
+func TestCloudflareWAFClearanceSetCookie(t *testing.T) {
+	var c gatsbie.CloudflareWAFCookies
+	if err := json.Unmarshal([]byte(`{"cf_clearance":"abc.123-1709294400; Max-Age=1800; Path=/; Secure"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.CfClearance != "abc.123-1709294400" || c.ClearanceMaxAge != 1800 {
+		t.Fatalf("cookies = %+v", c)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+	c.AddTo(req)
+	if got, err := req.Cookie("cf_clearance"); err != nil || got.Value != "abc.123-1709294400" {
+		t.Errorf("cf_clearance = %v, %v", got, err)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back gatsbie.CloudflareWAFCookies
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.CfClearance != c.CfClearance || back.ClearanceMaxAge != c.ClearanceMaxAge {
+		t.Errorf("round trip = %+v, want %+v", back, c)
+	}
+}
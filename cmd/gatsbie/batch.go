@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// batchJob is one line of a batch file:
+//
+//	{"id": "order-1", "task": "turnstile", "request": {"target_url": "...", "site_key": "..."}}
+//
+// The id is optional and is echoed in the result. The request has the
+// fields of the task's request type, under their JSON names.
+type batchJob struct {
+	ID      string          `json:"id,omitempty"`
+	Task    string          `json:"task"`
+	Request json.RawMessage `json:"request"`
+
+	line   int
+	solver solver
+	req    any
+}
+
+// batchResult is one line of batch output.
+type batchResult struct {
+	Line     int         `json:"line"`
+	ID       string      `json:"id,omitempty"`
+	Task     string      `json:"task"`
+	Response any         `json:"response,omitempty"`
+	Error    *batchError `json:"error,omitempty"`
+
+	err error
+}
+
+// batchError describes a failed job.
+type batchError struct {
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// runBatch runs the solve jobs in a JSONL file, or standard input for "-".
+// Every line is validated before any job runs. Results are written in
+// input order, one per line in JSON output. If any job fails the command
+// fails with the exit status of the first failure.
+func (c *cli) runBatch(ctx context.Context, args []string) error {
+	fs := c.flagSet("batch", "<file>")
+	concurrency := fs.Int("concurrency", 4, "number of jobs to run at once")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errFlagParse
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errFlagParse
+	}
+	if *concurrency < 1 {
+		return usageErrorf("batch: -concurrency must be at least 1")
+	}
+
+	var in io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	jobs, err := readBatch(in)
+	if err != nil {
+		return err
+	}
+
+	client, err := c.gatsbieClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	results := make([]batchResult, len(jobs))
+	done := make([]chan struct{}, len(jobs))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	sem := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = c.runJob(ctx, client, &jobs[i])
+		}(i)
+	}
+
+	var firstErr error
+	for i := range jobs {
+		<-done[i]
+		if err := c.printResult(results[i]); err != nil {
+			return err
+		}
+		if firstErr == nil && results[i].err != nil {
+			firstErr = results[i].err
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		failed := 0
+		for _, r := range results {
+			if r.err != nil {
+				failed++
+			}
+		}
+		return &batchFailure{failed: failed, total: len(jobs), first: firstErr}
+	}
+	return nil
+}
+
+// readBatch parses and validates every job in r. Blank lines are skipped.
+func readBatch(r io.Reader) ([]batchJob, error) {
+	var jobs []batchJob
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var job batchJob
+		if err := decodeStrict(data, &job); err != nil {
+			return nil, usageErrorf("batch: line %d: %v", line, err)
+		}
+		s, ok := findSolver(job.Task)
+		if !ok {
+			return nil, usageErrorf("batch: line %d: unknown task %q; tasks are %s", line, job.Task, solverNames())
+		}
+		job.line, job.solver, job.req = line, s, s.newRequest()
+		if len(job.Request) > 0 {
+			if err := decodeStrict(job.Request, job.req); err != nil {
+				return nil, usageErrorf("batch: line %d: request: %v", line, err)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("batch: %w", err)
+	}
+	return jobs, nil
+}
+
+func (c *cli) runJob(ctx context.Context, client *gatsbie.Client, job *batchJob) batchResult {
+	r := batchResult{Line: job.line, ID: job.ID, Task: job.Task}
+	resp, err := job.solver.solve(ctx, client, job.req, c.callOptions()...)
+	if err != nil {
+		r.err = err
+		r.Error = &batchError{Message: err.Error(), ExitCode: exitCode(err)}
+		var apiErr *gatsbie.APIError
+		if errors.As(err, &apiErr) {
+			r.Error.Code = apiErr.Code
+		}
+		return r
+	}
+	r.Response = resp
+	return r
+}
+
+func (c *cli) printResult(r batchResult) error {
+	if c.output == "json" {
+		return json.NewEncoder(c.stdout).Encode(r)
+	}
+	label := fmt.Sprintf("line %d", r.Line)
+	if r.ID != "" {
+		label += " (" + r.ID + ")"
+	}
+	if r.Error != nil {
+		_, err := fmt.Fprintf(c.stdout, "%s %s: error: %s\n", label, r.Task, r.Error.Message)
+		return err
+	}
+	_, err := fmt.Fprintf(c.stdout, "%s %s: ok\n", label, r.Task)
+	return err
+}
+
+// batchFailure is returned when some batch jobs failed. Its exit status is
+// that of the first failure.
+type batchFailure struct {
+	failed, total int
+	first         error
+}
+
+func (e *batchFailure) Error() string {
+	return fmt.Sprintf("gatsbie: batch: %d of %d jobs failed", e.failed, e.total)
+}
+
+func (e *batchFailure) Unwrap() error {
+	return e.first
+}
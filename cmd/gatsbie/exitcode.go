@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/target"
+)
+
+// Exit statuses. They are part of the command's interface; do not
+// renumber them.
+const (
+	exitOK                   = 0
+	exitError                = 1
+	exitUsage                = 2
+	exitAuthFailed           = 3
+	exitInsufficientCredits  = 4
+	exitInvalidRequest       = 5
+	exitSolveFailed          = 6
+	exitUpstreamError        = 7
+	exitInternalError        = 8
+	exitNotFound             = 9
+	exitInventoryUnavailable = 10
+)
+
+// errFlagParse reports a flag error that the flag package has already
+// printed.
+var errFlagParse = errors.New("invalid flags")
+
+// usageError is an invalid command line or batch file.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return "gatsbie: " + e.msg
+}
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCode maps err to an exit status.
+func exitCode(err error) int {
+	var usageErr *usageError
+	var apiErr *gatsbie.APIError
+	var targetErr *target.APIError
+	var cfgErr *gatsbie.ConfigError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errFlagParse), errors.As(err, &usageErr), errors.As(err, &cfgErr):
+		return exitUsage
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.IsAuthError():
+			return exitAuthFailed
+		case apiErr.IsInsufficientCredits():
+			return exitInsufficientCredits
+		case apiErr.IsInvalidRequest():
+			return exitInvalidRequest
+		case apiErr.IsSolveFailed():
+			return exitSolveFailed
+		case apiErr.IsUpstreamError():
+			return exitUpstreamError
+		case apiErr.IsInternalError():
+			return exitInternalError
+		}
+	case errors.As(err, &targetErr):
+		switch {
+		case targetErr.IsInventoryUnavailable():
+			return exitInventoryUnavailable
+		case targetErr.IsUnauthorized():
+			return exitAuthFailed
+		case targetErr.IsInvalidRequest():
+			return exitInvalidRequest
+		case targetErr.IsNotFound():
+			return exitNotFound
+		case targetErr.IsUpstreamError():
+			return exitUpstreamError
+		case targetErr.IsInternalError():
+			return exitInternalError
+		}
+	}
+	return exitError
+}
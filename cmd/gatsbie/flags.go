@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// requestFlags defines a flag for each field of a request struct, named
+// after the field's JSON key: site_key becomes -site-key, pxAppId becomes
+// -px-app-id and target_url becomes -url. Fields of nested structs are
+// flattened; fields of optional (pointer) structs are prefixed with the
+// parent's name, so request.method becomes -request-method. Map fields take
+// repeated key=value flags.
+//
+// Parsed values are held until apply is called, so that they can be laid
+// over a request decoded from -json.
+type requestFlags struct {
+	flags []*fieldFlag
+}
+
+// newRequestFlags defines flags on fs for the fields of req, which must be
+// a pointer to a struct.
+func newRequestFlags(fs *flag.FlagSet, req any) *requestFlags {
+	rf := &requestFlags{}
+	v := reflect.ValueOf(req).Elem()
+	rf.define(fs, v.Type(), "", func() reflect.Value { return v })
+	return rf
+}
+
+// define adds flags for the fields of struct type t. resolve returns the
+// struct value when a flag is applied; prefix is prepended to flag names.
+func (rf *requestFlags) define(fs *flag.FlagSet, t reflect.Type, prefix string, resolve func() reflect.Value) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = sf.Name
+		}
+
+		i := i
+		field := func() reflect.Value { return resolve().Field(i) }
+		name := prefix + flagName(key)
+		f := &fieldFlag{typ: sf.Type, resolve: field}
+		switch sf.Type.Kind() {
+		case reflect.Struct:
+			rf.define(fs, sf.Type, prefix, field)
+			continue
+		case reflect.Pointer:
+			if sf.Type.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			// Allocate the struct only when one of its flags is applied.
+			rf.define(fs, sf.Type.Elem(), name+"-", func() reflect.Value {
+				p := field()
+				if p.IsNil() {
+					p.Set(reflect.New(sf.Type.Elem()))
+				}
+				return p.Elem()
+			})
+			continue
+		case reflect.String, reflect.Int, reflect.Float64:
+			fs.Var(f, name, fmt.Sprintf("sets %q to `%s`", key, sf.Type.Kind()))
+		case reflect.Bool:
+			fs.Var(boolFieldFlag{f}, name, fmt.Sprintf("sets %q", key))
+		case reflect.Map:
+			if sf.Type.Key().Kind() != reflect.String || sf.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			fs.Var(f, name, fmt.Sprintf("adds a `key=value` entry to %q; repeatable", key))
+		default:
+			// Only settable through -json.
+			continue
+		}
+		rf.flags = append(rf.flags, f)
+	}
+}
+
+// apply copies the parsed flag values into the request. Map entries are
+// merged into any map the request already holds.
+func (rf *requestFlags) apply() {
+	for _, f := range rf.flags {
+		if !f.value.IsValid() {
+			continue
+		}
+		field := f.resolve()
+		if field.Kind() == reflect.Map && !field.IsNil() {
+			iter := f.value.MapRange()
+			for iter.Next() {
+				field.SetMapIndex(iter.Key(), iter.Value())
+			}
+			continue
+		}
+		field.Set(f.value)
+	}
+}
+
+// fieldFlag is a flag.Value for one request field.
+type fieldFlag struct {
+	typ     reflect.Type
+	resolve func() reflect.Value // returns the field, allocating parents
+	value   reflect.Value        // parsed value; invalid until the flag is set
+}
+
+func (f *fieldFlag) String() string {
+	return ""
+}
+
+func (f *fieldFlag) Set(s string) error {
+	t := f.typ
+	switch t.Kind() {
+	case reflect.String:
+		f.value = reflect.ValueOf(s).Convert(t)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value = reflect.ValueOf(b).Convert(t)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value = reflect.ValueOf(n).Convert(t)
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.value = reflect.ValueOf(x).Convert(t)
+	case reflect.Map:
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want key=value, got %q", s)
+		}
+		if !f.value.IsValid() {
+			f.value = reflect.MakeMap(t)
+		}
+		f.value.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), reflect.ValueOf(v).Convert(t.Elem()))
+	}
+	return nil
+}
+
+// boolFieldFlag lets a bool field be set with a bare -flag.
+type boolFieldFlag struct {
+	*fieldFlag
+}
+
+func (boolFieldFlag) IsBoolFlag() bool {
+	return true
+}
+
+// flagName converts a JSON key to a flag name.
+func flagName(key string) string {
+	if key == "target_url" {
+		return "url"
+	}
+	var b strings.Builder
+	for i, r := range strings.TrimLeft(key, "_") {
+		switch {
+		case r == '_':
+			b.WriteByte('-')
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,214 @@
+// Command gatsbie solves challenges and calls the Target API from the
+// command line.
+//
+// Usage:
+//
+//	gatsbie [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	health                    check the Gatsbie API
+//	solve <task> [flags]      solve a challenge, such as turnstile or akamai
+//	batch [flags] <file>      run solve jobs from a JSONL file
+//	target <command> [flags]  call the Target API: health, ping, stores,
+//	                          product or cart add
+//
+// Run "gatsbie solve" for the list of tasks and "gatsbie solve <task> -h"
+// for a task's flags. Each flag sets the request field with the same JSON
+// name, so -site-key sets site_key; -url sets target_url.
+//
+// Settings are read from the environment and config files in the same way
+// as gatsbie.NewClientFromEnv: GATSBIE_API_KEY, GATSBIE_BASE_URL,
+// GATSBIE_CONFIG and so on. The -config and -profile flags override
+// GATSBIE_CONFIG and GATSBIE_PROFILE.
+//
+// The exit status reports the kind of failure:
+//
+//	0   success
+//	1   other error
+//	2   invalid command line, batch file or configuration
+//	3   AUTH_FAILED, or UNAUTHORIZED from Target
+//	4   INSUFFICIENT_CREDITS
+//	5   INVALID_REQUEST
+//	6   SOLVE_FAILED
+//	7   UPSTREAM_ERROR
+//	8   INTERNAL_ERROR
+//	9   NOT_FOUND from Target
+//	10  INVENTORY_UNAVAILABLE from Target
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/target"
+)
+
+const usage = `Usage: gatsbie [flags] <command> [arguments]
+
+Commands:
+  health                    check the Gatsbie API
+  solve <task> [flags]      solve a challenge, such as turnstile or akamai
+  batch [flags] <file>      run solve jobs from a JSONL file
+  target <command> [flags]  call the Target API: health, ping, stores,
+                            product or cart add
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// cli holds the global flags and output streams shared by every command.
+type cli struct {
+	stdout, stderr io.Writer
+	output         string
+	timeout        time.Duration
+
+	// configPath and profile override GATSBIE_CONFIG and GATSBIE_PROFILE.
+	configPath, profile string
+}
+
+// run executes the command line args and returns the exit status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("gatsbie", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.configPath, "config", "", "config `file` (overrides GATSBIE_CONFIG)")
+	fs.StringVar(&c.profile, "profile", "", "config file `profile` (overrides GATSBIE_PROFILE)")
+	fs.StringVar(&c.output, "output", "text", "output `format`: text or json")
+	fs.StringVar(&c.output, "o", "text", "shorthand for -output")
+	fs.DurationVar(&c.timeout, "timeout", 0, "bound each call, retries included (0 uses the client timeout)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if c.output != "text" && c.output != "json" {
+		fmt.Fprintf(stderr, "gatsbie: unknown output format %q\n", c.output)
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	var err error
+	switch cmd {
+	case "health":
+		err = c.runHealth(ctx, rest)
+	case "solve":
+		err = c.runSolve(ctx, rest)
+	case "batch":
+		err = c.runBatch(ctx, rest)
+	case "target":
+		err = c.runTarget(ctx, rest)
+	default:
+		err = usageErrorf("unknown command %q", cmd)
+	}
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	// Flag parse errors have already been printed by the flag package.
+	if !errors.Is(err, errFlagParse) {
+		fmt.Fprintln(stderr, err)
+	}
+	return exitCode(err)
+}
+
+func (c *cli) runHealth(ctx context.Context, args []string) error {
+	fs := c.flagSet("health", "")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	client, err := c.gatsbieClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	resp, err := client.Health(ctx, c.callOptions()...)
+	if err != nil {
+		return err
+	}
+	return c.print(resp)
+}
+
+// flagSet returns a flag set for a subcommand that reports errors instead
+// of exiting.
+func (c *cli) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: gatsbie %s [flags]", name)
+		if args != "" {
+			fmt.Fprint(c.stderr, " "+args)
+		}
+		fmt.Fprintln(c.stderr)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args and rejects positional arguments.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errFlagParse
+	}
+	if fs.NArg() > 0 {
+		return usageErrorf("%s: unexpected argument %q", fs.Name(), fs.Arg(0))
+	}
+	return nil
+}
+
+func (c *cli) gatsbieClient() (*gatsbie.Client, error) {
+	cfg, err := gatsbie.ConfigFromEnv(c.configPath, c.profile)
+	if err != nil {
+		return nil, err
+	}
+	return gatsbie.NewClientFromConfig(cfg)
+}
+
+func (c *cli) targetClient() (*target.Client, error) {
+	cfg, err := target.ConfigFromEnv(c.configPath, c.profile)
+	if err != nil {
+		return nil, err
+	}
+	return target.NewClientFromConfig(cfg)
+}
+
+func (c *cli) callOptions() []gatsbie.CallOption {
+	if c.timeout <= 0 {
+		return nil
+	}
+	return []gatsbie.CallOption{gatsbie.WithCallTimeout(c.timeout)}
+}
+
+func (c *cli) targetCallOptions() []target.CallOption {
+	if c.timeout <= 0 {
+		return nil
+	}
+	return []target.CallOption{target.WithCallTimeout(c.timeout)}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+// runCLI runs the command against srv and returns its exit status and
+// output.
+func runCLI(t *testing.T, srv *gatsbietest.Server, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("GATSBIE_CONFIG", "")
+	t.Setenv("GATSBIE_API_KEY", gatsbietest.TestAPIKey)
+	if srv != nil {
+		t.Setenv("GATSBIE_BASE_URL", srv.URL)
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSolveFlags(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+
+	code, stdout, stderr := runCLI(t, srv, "-o", "json", "solve", "turnstile",
+		"-url", "https://example.com", "-site-key", "0xKEY", "-proxy", "http://proxy:8080")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	srv.ExpectField(gatsbietest.TaskTurnstile, "target_url", "https://example.com")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "site_key", "0xKEY")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "proxy", "http://proxy:8080")
+
+	var resp gatsbie.SolveResponse[gatsbie.TurnstileSolution]
+	if err := json.Unmarshal([]byte(stdout), &resp); err != nil {
+		t.Fatalf("output is not a solve response: %v\n%s", err, stdout)
+	}
+	if !resp.Success || resp.Solution.Token == "" {
+		t.Errorf("resp = %+v", resp)
+	}
+}
+
+func TestSolveJSONAndNestedFlags(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+
+	code, _, stderr := runCLI(t, srv, "solve", "castle",
+		"-json", `{"target_url":"https://example.com","config_json":{"pk":"from-json","wUrl":"w"}}`,
+		"-pk", "from-flag", "-avoid-cookies")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	srv.ExpectField(gatsbietest.TaskCastle, "target_url", "https://example.com")
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.pk", "from-flag")
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.wUrl", "w")
+	srv.ExpectField(gatsbietest.TaskCastle, "config_json.avoidCookies", true)
+}
+
+func TestSolveOptionalStructFlags(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+
+	code, _, stderr := runCLI(t, srv, "solve", "shape-v2", "-url", "https://example.com",
+		"-request-method", "POST", "-request-headers", "Accept=*/*", "-request-headers", "X-A=b")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	srv.ExpectField(gatsbietest.TaskShapeV2, "metadata.request.method", "POST")
+	srv.ExpectField(gatsbietest.TaskShapeV2, "metadata.request.headers", map[string]any{"Accept": "*/*", "X-A": "b"})
+
+	// Without any -request-* flag the optional struct is left out.
+	if code, _, stderr := runCLI(t, srv, "solve", "shape-v2", "-url", "https://example.com"); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	srv.ExpectNoField(gatsbietest.TaskShapeV2, "metadata.request")
+}
+
+func TestTextOutput(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "tok123", UserAgent: "ua"}))
+
+	code, stdout, stderr := runCLI(t, srv, "solve", "turnstile")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	for _, want := range []string{"solution.token", "tok123", "success", "true"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{gatsbie.ErrCodeAuthFailed, exitAuthFailed},
+		{gatsbie.ErrCodeInsufficientCredits, exitInsufficientCredits},
+		{gatsbie.ErrCodeInvalidRequest, exitInvalidRequest},
+		{gatsbie.ErrCodeSolveFailed, exitSolveFailed},
+		{gatsbie.ErrCodeUpstreamError, exitUpstreamError},
+		{gatsbie.ErrCodeInternalError, exitInternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			srv.Handle(gatsbietest.TaskAkamai, gatsbietest.Error(tt.code, "failed"))
+
+			code, _, stderr := runCLI(t, srv, "solve", "akamai", "-url", "https://example.com")
+			if code != tt.want {
+				t.Errorf("exit %d, want %d", code, tt.want)
+			}
+			if !strings.Contains(stderr, tt.code) {
+				t.Errorf("stderr = %q, want the error code", stderr)
+			}
+		})
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"bogus"},
+		{"solve"},
+		{"solve", "bogus"},
+		{"solve", "turnstile", "-bogus"},
+		{"solve", "turnstile", "-json", `{"bogus":1}`},
+		{"-o", "yaml", "health"},
+		{"target", "cart"},
+	}
+	for _, args := range tests {
+		if code, _, _ := runCLI(t, nil, args...); code != exitUsage {
+			t.Errorf("%q: exit %d, want %d", args, code, exitUsage)
+		}
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	t.Setenv("GATSBIE_CONFIG", "")
+	t.Setenv("GATSBIE_API_KEY", "")
+	t.Setenv("GATSBIE_API_KEYS", "")
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"health"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("exit %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(stderr.String(), "no API key") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestConfigAndProfileFlags(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	for _, name := range []string{"GATSBIE_CONFIG", "GATSBIE_PROFILE", "GATSBIE_API_KEY", "GATSBIE_API_KEYS", "GATSBIE_BASE_URL", "GATSBIE_BASE_URLS"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "gatsbie.yaml")
+	config := "profiles:\n  default:\n    api_key: " + gatsbietest.TestAPIKey + "\n    base_url: http://127.0.0.1:1\n" +
+		"  prod:\n    api_key: " + gatsbietest.TestAPIKey + "\n    base_url: " + srv.URL + "\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-config", path, "-profile", "prod", "solve", "turnstile", "-url", "https://example.com", "-site-key", "0xKEY"}
+	if code := run(context.Background(), args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if n := len(srv.Requests(gatsbietest.TaskTurnstile)); n != 1 {
+		t.Errorf("prod profile server got %d requests, want 1", n)
+	}
+
+	// The flags apply to that run only.
+	for _, name := range []string{"GATSBIE_CONFIG", "GATSBIE_PROFILE"} {
+		if v := os.Getenv(name); v != "" {
+			t.Errorf("%s = %q after run, want it unchanged", name, v)
+		}
+	}
+	stderr.Reset()
+	if code := run(context.Background(), []string{"health"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("run without -config: exit %d, want %d (%s)", code, exitUsage, stderr.String())
+	}
+}
+
+func TestBatch(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskAkamai, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no luck"))
+
+	path := filepath.Join(t.TempDir(), "jobs.jsonl")
+	jobs := `{"id":"a","task":"turnstile","request":{"target_url":"https://a.example","site_key":"k"}}
+
+{"id":"b","task":"akamai","request":{"target_url":"https://b.example"}}
+{"task":"vercel"}
+`
+	if err := os.WriteFile(path, []byte(jobs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, _ := runCLI(t, srv, "-o", "json", "batch", "-concurrency", "2", path)
+	if code != exitSolveFailed {
+		t.Errorf("exit %d, want %d", code, exitSolveFailed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d result lines, want 3:\n%s", len(lines), stdout)
+	}
+	want := []struct {
+		line int
+		id   string
+		task string
+		code string
+	}{
+		{1, "a", "turnstile", ""},
+		{3, "b", "akamai", gatsbie.ErrCodeSolveFailed},
+		{4, "", "vercel", ""},
+	}
+	for i, l := range lines {
+		var r struct {
+			Line     int             `json:"line"`
+			ID       string          `json:"id"`
+			Task     string          `json:"task"`
+			Response json.RawMessage `json:"response"`
+			Error    *struct {
+				Code     string `json:"code"`
+				ExitCode int    `json:"exit_code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		w := want[i]
+		if r.Line != w.line || r.ID != w.id || r.Task != w.task {
+			t.Errorf("result %d = %+v, want %+v", i, r, w)
+		}
+		if w.code == "" && (r.Error != nil || len(r.Response) == 0) {
+			t.Errorf("result %d: want a response, got error %+v", i, r.Error)
+		}
+		if w.code != "" && (r.Error == nil || r.Error.Code != w.code || r.Error.ExitCode != exitSolveFailed) {
+			t.Errorf("result %d: error = %+v, want %s", i, r.Error, w.code)
+		}
+	}
+	srv.ExpectField(gatsbietest.TaskTurnstile, "site_key", "k")
+}
+
+func TestBatchValidatesBeforeRunning(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "jobs.jsonl")
+	jobs := `{"task":"turnstile"}
+{"task":"nope"}
+`
+	if err := os.WriteFile(path, []byte(jobs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, srv, "batch", path)
+	if code != exitUsage {
+		t.Errorf("exit %d, want %d", code, exitUsage)
+	}
+	if !strings.Contains(stderr, "line 2") {
+		t.Errorf("stderr = %q, want the line number", stderr)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("server saw %d requests, want none", n)
+	}
+}
+
+func TestTargetProduct(t *testing.T) {
+	var gotPath, gotProxy string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotProxy = r.URL.Path, r.URL.Query().Get("proxy")
+		w.Write([]byte(`{"tcin":"12345","title":"Widget","in_stock":true}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GATSBIE_TARGET_BASE_URL", srv.URL)
+	t.Setenv("GATSBIE_PROXY", "http://proxy:8080")
+
+	code, stdout, stderr := runCLI(t, nil, "target", "product", "-tcin", "12345")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if gotPath != "/api/v1/products/12345" || gotProxy != "http://proxy:8080" {
+		t.Errorf("request path %q proxy %q", gotPath, gotProxy)
+	}
+	if !strings.Contains(stdout, "Widget") {
+		t.Errorf("stdout = %q", stdout)
+	}
+}
+
+func TestTargetExitCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"product not found"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("GATSBIE_TARGET_BASE_URL", srv.URL)
+
+	if code, _, _ := runCLI(t, nil, "target", "product", "-tcin", "1", "-proxy", "http://p:1"); code != exitNotFound {
+		t.Errorf("exit %d, want %d", code, exitNotFound)
+	}
+	// Validation failures are reported before any request is made.
+	if code, _, _ := runCLI(t, nil, "target", "cart", "add", "-tcin", "1"); code != exitInvalidRequest {
+		t.Errorf("exit %d, want %d", code, exitInvalidRequest)
+	}
+}
+
+func TestFlagName(t *testing.T) {
+	tests := map[string]string{
+		"site_key":       "site-key",
+		"target_url":     "url",
+		"pxAppId":        "px-app-id",
+		"wUrl":           "w-url",
+		"reese84_js_url": "reese84-js-url",
+		"pk":             "pk",
+	}
+	for key, want := range tests {
+		if got := flagName(key); got != want {
+			t.Errorf("flagName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
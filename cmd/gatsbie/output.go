@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// print writes v in the selected output format.
+func (c *cli) print(v any) error {
+	if c.output == "json" {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	return printText(c.stdout, v)
+}
+
+// printText writes v as aligned "key value" lines, one per leaf of its
+// JSON form, with nested keys joined by dots. The elements of a top-level
+// list are separated by blank lines.
+func printText(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if list, ok := generic.([]any); ok {
+		for i, item := range list {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+			writeFlat(tw, "", item)
+		}
+	} else {
+		writeFlat(tw, "", generic)
+	}
+	return tw.Flush()
+}
+
+func writeFlat(w io.Writer, prefix string, v any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeFlat(w, join(k), v[k])
+		}
+	case []any:
+		for i, item := range v {
+			writeFlat(w, prefix+"["+strconv.Itoa(i)+"]", item)
+		}
+	case nil:
+		// Omit null values.
+	case string:
+		fmt.Fprintf(w, "%s\t%s\n", prefix, v)
+	case float64:
+		fmt.Fprintf(w, "%s\t%s\n", prefix, strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		fmt.Fprintf(w, "%s\t%v\n", prefix, v)
+	}
+}
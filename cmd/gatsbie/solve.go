@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// solver is a solve task the CLI can run.
+type solver struct {
+	name       string
+	desc       string
+	newRequest func() any
+	solve      func(ctx context.Context, c *gatsbie.Client, req any, opts ...gatsbie.CallOption) (any, error)
+}
+
+// newSolver adapts a Client.SolveXxx method expression to a solver.
+func newSolver[Req, Sol any](name, desc string, fn func(*gatsbie.Client, context.Context, *Req, ...gatsbie.CallOption) (*gatsbie.SolveResponse[Sol], error)) solver {
+	return solver{
+		name:       name,
+		desc:       desc,
+		newRequest: func() any { return new(Req) },
+		solve: func(ctx context.Context, c *gatsbie.Client, req any, opts ...gatsbie.CallOption) (any, error) {
+			resp, err := fn(c, ctx, req.(*Req), opts...)
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		},
+	}
+}
+
+// solvers lists every solve task, in the order the SDK declares them.
+var solvers = []solver{
+	newSolver("datadome", "Datadome device check", (*gatsbie.Client).SolveDatadome),
+	newSolver("recaptcha", "reCAPTCHA v2/v3 (Universal)", (*gatsbie.Client).SolveRecaptcha),
+	newSolver("recaptcha-enterprise", "reCAPTCHA Enterprise", (*gatsbie.Client).SolveRecaptchaEnterprise),
+	newSolver("akamai", "Akamai Bot Manager", (*gatsbie.Client).SolveAkamai),
+	newSolver("vercel", "Vercel bot protection", (*gatsbie.Client).SolveVercel),
+	newSolver("shape", "Shape antibot", (*gatsbie.Client).SolveShape),
+	newSolver("shape-v2", "Shape antibot (v2)", (*gatsbie.Client).SolveShapeV2),
+	newSolver("turnstile", "Cloudflare Turnstile", (*gatsbie.Client).SolveTurnstile),
+	newSolver("perimeterx", "PerimeterX Invisible", (*gatsbie.Client).SolvePerimeterX),
+	newSolver("cloudflare-waf", "Cloudflare WAF", (*gatsbie.Client).SolveCloudflareWAF),
+	newSolver("datadome-slider", "Datadome slider captcha", (*gatsbie.Client).SolveDatadomeSlider),
+	newSolver("captchafox", "CaptchaFox", (*gatsbie.Client).SolveCaptchaFox),
+	newSolver("castle", "Castle", (*gatsbie.Client).SolveCastle),
+	newSolver("reese84", "Incapsula Reese84", (*gatsbie.Client).SolveReese84),
+	newSolver("forter", "Forter", (*gatsbie.Client).SolveForter),
+	newSolver("funcaptcha", "FunCaptcha (Arkose Labs)", (*gatsbie.Client).SolveFuncaptcha),
+	newSolver("sbsd", "Akamai SBSD", (*gatsbie.Client).SolveSBSD),
+	newSolver("perimeterx-hold", "PerimeterX press-and-hold", (*gatsbie.Client).SolvePerimeterXHold),
+}
+
+func findSolver(name string) (solver, bool) {
+	for _, s := range solvers {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return solver{}, false
+}
+
+func solverNames() string {
+	names := make([]string, len(solvers))
+	for i, s := range solvers {
+		names[i] = s.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (c *cli) runSolve(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprintln(c.stderr, "Usage: gatsbie solve <task> [flags]\n\nTasks:")
+		for _, s := range solvers {
+			fmt.Fprintf(c.stderr, "  %-22s %s\n", s.name, s.desc)
+		}
+		if len(args) == 0 {
+			return errFlagParse
+		}
+		return nil
+	}
+	s, ok := findSolver(args[0])
+	if !ok {
+		return usageErrorf("unknown task %q; tasks are %s", args[0], solverNames())
+	}
+
+	fs := c.flagSet("solve "+s.name, "")
+	req := s.newRequest()
+	rf := newRequestFlags(fs, req)
+	raw := fs.String("json", "", "request as a JSON `object`; other flags override its fields")
+	if err := parse(fs, args[1:]); err != nil {
+		return err
+	}
+	if *raw != "" {
+		if err := decodeStrict([]byte(*raw), req); err != nil {
+			return usageErrorf("solve %s: -json: %v", s.name, err)
+		}
+	}
+	rf.apply()
+
+	client, err := c.gatsbieClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	resp, err := s.solve(ctx, client, req, c.callOptions()...)
+	if err != nil {
+		return err
+	}
+	return c.print(resp)
+}
+
+// decodeStrict decodes a JSON request, rejecting unknown fields so that a
+// misspelt field is not silently dropped.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
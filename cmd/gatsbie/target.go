@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/target"
+)
+
+const targetUsage = `Usage: gatsbie target <command> [flags]
+
+Commands:
+  health     check the Target API
+  ping       check connectivity and show quota
+  stores     find stores near a location
+  product    show a product
+  cart add   add an item to the cart
+`
+
+func (c *cli) runTarget(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, targetUsage)
+		return errFlagParse
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(c.stderr, targetUsage)
+		return nil
+	}
+
+	var (
+		fs  = c.flagSet("target "+args[0], "")
+		run func(context.Context, *target.Client) (any, error)
+	)
+	rest := args[1:]
+	switch args[0] {
+	case "health":
+		run = func(ctx context.Context, client *target.Client) (any, error) {
+			return client.Health(ctx, c.targetCallOptions()...)
+		}
+	case "ping":
+		run = func(ctx context.Context, client *target.Client) (any, error) {
+			return client.Ping(ctx, c.targetCallOptions()...)
+		}
+	case "stores":
+		var req target.NearbyStoresRequest
+		fs.Float64Var(&req.Lat, "lat", 0, "latitude")
+		fs.Float64Var(&req.Lng, "lng", 0, "longitude")
+		fs.IntVar(&req.Limit, "limit", 0, "maximum number of stores (default 10)")
+		fs.Float64Var(&req.Radius, "radius", 0, "search radius in miles (default 50)")
+		run = func(ctx context.Context, client *target.Client) (any, error) {
+			return client.GetNearbyStores(ctx, req, c.targetCallOptions()...)
+		}
+	case "product":
+		var req target.GetProductRequest
+		fs.StringVar(&req.TCIN, "tcin", "", "product TCIN (required)")
+		fs.StringVar(&req.StoreID, "store-id", "", "store for availability (default 3229)")
+		fs.StringVar(&req.Proxy, "proxy", "", "proxy `URL` (default GATSBIE_PROXY)")
+		run = func(ctx context.Context, client *target.Client) (any, error) {
+			return client.GetProduct(ctx, req, c.targetCallOptions()...)
+		}
+	case "cart":
+		if len(rest) == 0 || rest[0] != "add" {
+			fmt.Fprint(c.stderr, targetUsage)
+			return errFlagParse
+		}
+		fs = c.flagSet("target cart add", "")
+		rest = rest[1:]
+		var req target.AddToCartRequest
+		fulfillment := fs.String("fulfillment", "", "fulfillment `type`: SHIP, CURBSIDE or STORE_PICKUP")
+		fs.StringVar(&req.TCIN, "tcin", "", "product TCIN (required)")
+		fs.IntVar(&req.Quantity, "quantity", 1, "quantity to add")
+		fs.StringVar(&req.AccessToken, "access-token", "", "Target account access `token` (required)")
+		fs.StringVar(&req.Proxy, "proxy", "", "proxy `URL` (default GATSBIE_PROXY)")
+		fs.StringVar(&req.StoreID, "store-id", "", "store for CURBSIDE and STORE_PICKUP")
+		run = func(ctx context.Context, client *target.Client) (any, error) {
+			req.FulfillmentType = target.FulfillmentType(*fulfillment)
+			return client.AddToCart(ctx, req, c.targetCallOptions()...)
+		}
+	default:
+		return usageErrorf("unknown target command %q", args[0])
+	}
+
+	if err := parse(fs, rest); err != nil {
+		return err
+	}
+	client, err := c.targetClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	resp, err := run(ctx, client)
+	if err != nil {
+		return err
+	}
+	return c.print(resp)
+}
@@ -0,0 +1,234 @@
+package gatsbie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/httpbody"
+)
+
+// DatadomeChallengeType is the kind of challenge a Datadome block page
+// serves.
+type DatadomeChallengeType string
+
+// Datadome challenge types.
+const (
+	DatadomeDeviceCheck DatadomeChallengeType = "device-check" // interstitial
+	DatadomeSlider      DatadomeChallengeType = "slider"       // captcha
+)
+
+// datadomeHost serves Datadome challenges when the block page names none.
+const datadomeHost = "geo.captcha-delivery.com"
+
+// DatadomeChallenge describes the challenge in a response blocked by
+// Datadome.
+type DatadomeChallenge struct {
+	Type DatadomeChallengeType
+
+	// URL is the challenge URL on geo.captcha-delivery.com.
+	URL string
+
+	// PageURL and Method are those of the blocked request.
+	PageURL string
+	Method  string
+
+	// Blocked is set when Datadome has banned the IP outright (t=bv). No
+	// solve will pass; retry with a different proxy.
+	Blocked bool
+}
+
+var (
+	datadomeVar = regexp.MustCompile(`var\s+dd\s*=\s*(\{[^}]*\})`)
+	datadomeURL = regexp.MustCompile(`https?://[a-z0-9.-]*captcha-delivery\.com/(?:captcha|interstitial)/\?[^"'\s<>\\]+`)
+)
+
+// ParseDatadomeChallenge finds the Datadome challenge in resp, a response
+// blocked by Datadome. It understands the HTML block page, with its dd
+// object, and the JSON body Datadome returns to API calls. The body is
+// read and replaced, so resp can still be read by the caller.
+//
+// The returned error is an *APIError with code INVALID_REQUEST if resp
+// holds no Datadome challenge.
+func ParseDatadomeChallenge(resp *http.Response) (*DatadomeChallenge, error) {
+	return parseDatadomeChallenge(resp, httpbody.DefaultMaxSize)
+}
+
+func parseDatadomeChallenge(resp *http.Response, limit int64) (*DatadomeChallenge, error) {
+	if resp == nil || resp.Body == nil {
+		return nil, invalidRequest("datadome: no blocked response")
+	}
+	data, err := readBlockedBody(resp, limit)
+	if err != nil {
+		return nil, fmt.Errorf("datadome: %w", err)
+	}
+
+	ch := &DatadomeChallenge{Method: http.MethodGet}
+	if resp.Request != nil {
+		ch.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			ch.PageURL = resp.Request.URL.String()
+		}
+	}
+
+	if ch.URL = datadomeURLFromJSON(data); ch.URL == "" {
+		if m := datadomeVar.FindSubmatch(data); m != nil {
+			ch.URL, err = datadomeURLFromVar(m[1], ch.PageURL, datadomeCookie(resp))
+			if err != nil {
+				return nil, err
+			}
+		} else if m := datadomeURL.Find(data); m != nil {
+			ch.URL = html.UnescapeString(string(m))
+		}
+	}
+	if ch.URL == "" {
+		return nil, invalidRequest("datadome: no challenge found in response (status %d)", resp.StatusCode)
+	}
+
+	u, err := url.Parse(ch.URL)
+	if err != nil {
+		return nil, invalidRequest("datadome: invalid challenge URL %q: %v", ch.URL, err)
+	}
+	ch.Type = DatadomeSlider
+	if strings.HasPrefix(u.Path, "/interstitial") {
+		ch.Type = DatadomeDeviceCheck
+	}
+	ch.Blocked = u.Query().Get("t") == "bv"
+	return ch, nil
+}
+
+// datadomeURLFromJSON returns the url member of a JSON block response.
+func datadomeURLFromJSON(data []byte) string {
+	var body struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(data, &body) != nil || !datadomeURL.MatchString(body.URL) {
+		return ""
+	}
+	return body.URL
+}
+
+// datadomeURLFromVar builds the challenge URL the way the block page's
+// script does from its dd object, which is written with single quotes.
+func datadomeURLFromVar(obj []byte, pageURL, cookie string) (string, error) {
+	var dd map[string]any
+	if err := json.Unmarshal(bytes.ReplaceAll(obj, []byte("'"), []byte(`"`)), &dd); err != nil {
+		return "", invalidRequest("datadome: failed to parse dd object: %v", err)
+	}
+	field := func(name string) string {
+		switch v := dd[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	host := field("host")
+	if host == "" {
+		host = datadomeHost
+	}
+	path := "/captcha/"
+	if field("rt") == "i" {
+		path = "/interstitial/"
+	}
+	if c := field("cookie"); c != "" {
+		cookie = c
+	}
+
+	q := url.Values{}
+	q.Set("initialCid", field("cid"))
+	q.Set("hash", field("hsh"))
+	q.Set("cid", cookie)
+	if t := field("t"); t != "" {
+		q.Set("t", t)
+	}
+	q.Set("referer", pageURL)
+	q.Set("s", field("s"))
+	q.Set("e", field("e"))
+	if b := field("b"); b != "" {
+		q.Set("b", b)
+	}
+	if path == "/interstitial/" {
+		q.Set("dm", "cd")
+	}
+	return "https://" + host + path + "?" + q.Encode(), nil
+}
+
+// datadomeCookie returns the datadome cookie set by resp or sent with its
+// request.
+func datadomeCookie(resp *http.Response) string {
+	for _, c := range resp.Cookies() {
+		if c.Name == "datadome" {
+			return c.Value
+		}
+	}
+	if resp.Request != nil {
+		if c, err := resp.Request.Cookie("datadome"); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// SolveDatadomeAuto solves the Datadome challenge in blockedResp, a
+// response blocked by Datadome. It reads the block page to choose between
+// SolveDatadome for a device check and SolveDatadomeSlider for a slider,
+// and fills in the target URL and method from the blocked request for
+// either. Either way the result is returned as a DatadomeSolution.
+//
+// blockedResp's body is read and replaced. An *APIError with code
+// INVALID_REQUEST is returned, without calling the API, if blockedResp
+// holds no challenge or Datadome has banned the IP.
+func (c *Client) SolveDatadomeAuto(ctx context.Context, blockedResp *http.Response, proxy string, opts ...CallOption) (*SolveResponse[DatadomeSolution], error) {
+	ch, err := parseDatadomeChallenge(blockedResp, c.maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if ch.Blocked {
+		return nil, invalidRequest("datadome: IP is blocked (t=bv); retry with a different proxy")
+	}
+
+	// Both solvers load the blocked page, so they are given its URL and
+	// method; without a blocked request the challenge URL itself is loaded.
+	targetURL, method := ch.PageURL, ch.Method
+	if targetURL == "" {
+		targetURL, method = ch.URL, http.MethodGet
+	}
+	if ch.Type == DatadomeDeviceCheck {
+		return c.SolveDatadome(ctx, &DatadomeRequest{
+			Proxy:        proxy,
+			TargetURL:    targetURL,
+			TargetMethod: method,
+		}, opts...)
+	}
+
+	resp, err := c.SolveDatadomeSlider(ctx, &DatadomeSliderRequest{
+		Proxy:        proxy,
+		TargetURL:    targetURL,
+		TargetMethod: method,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SolveResponse[DatadomeSolution]{
+		Success:        resp.Success,
+		TaskID:         resp.TaskID,
+		Service:        resp.Service,
+		Solution:       DatadomeSolution(resp.Solution),
+		Cost:           resp.Cost,
+		SolveTime:      resp.SolveTime,
+		IdempotencyKey: resp.IdempotencyKey,
+		RawSolution:    resp.RawSolution,
+		Raw:            resp.Raw,
+		ReceivedAt:     resp.ReceivedAt,
+	}, nil
+}
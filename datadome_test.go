@@ -0,0 +1,187 @@
+package gatsbie_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+// blockedResponse builds a 403 response to method pageURL with the body of
+// the named fixture in testdata/datadome.
+func blockedResponse(t *testing.T, fixture, method, pageURL string) *http.Response {
+	t.Helper()
+	body := readFixture(t, "datadome", fixture)
+	req, err := http.NewRequest(method, pageURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     http.Header{"X-Datadome": {"protected"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestParseDatadomeChallenge(t *testing.T) {
+	tests := []struct {
+		fixture string
+		typ     gatsbie.DatadomeChallengeType
+		path    string
+		query   map[string]string
+		blocked bool
+	}{
+		{
+			fixture: "slider.html",
+			typ:     gatsbie.DatadomeSlider,
+			path:    "/captcha/",
+			query: map[string]string{
+				"initialCid": "AHrlqAAAAAMAsLVLjyNBtXkAMRtbUg==",
+				"hash":       "2211F522B61E269B869FA6EAFFB5E1",
+				"cid":        "kPhBNjW~uI5Vx1dqXG0eFTgbdS3z_Q8a",
+				"t":          "fe",
+				"s":          "17434",
+				"referer":    "https://www.example.com/products",
+			},
+		},
+		{
+			fixture: "interstitial.html",
+			typ:     gatsbie.DatadomeDeviceCheck,
+			path:    "/interstitial/",
+			query: map[string]string{
+				"initialCid": "AHrlqAAAAAMAtQ5dWkw3k9sAiXbBhg==",
+				"cid":        "u8Zy3rQ~2Lm0pAvB_eXcWd7oNsKj1HtG",
+				"b":          "1068921",
+				"dm":         "cd",
+				"referer":    "https://www.example.com/products",
+			},
+		},
+		{
+			fixture: "api.json",
+			typ:     gatsbie.DatadomeSlider,
+			path:    "/captcha/",
+			query:   map[string]string{"referer": "https://www.example.com/api/cart", "t": "fe"},
+		},
+		{
+			fixture: "iframe.html",
+			typ:     gatsbie.DatadomeDeviceCheck,
+			path:    "/interstitial/",
+			query:   map[string]string{"b": "1068921", "cid": "u8Zy3rQ~2Lm0pAvB_eXcWd7oNsKj1HtG"},
+		},
+		{
+			fixture: "blocked.html",
+			typ:     gatsbie.DatadomeSlider,
+			path:    "/captcha/",
+			query:   map[string]string{"t": "bv"},
+			blocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			resp := blockedResponse(t, tt.fixture, http.MethodPost, "https://www.example.com/products")
+			ch, err := gatsbie.ParseDatadomeChallenge(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ch.Type != tt.typ || ch.Blocked != tt.blocked {
+				t.Errorf("Type = %q, Blocked = %v, want %q, %v", ch.Type, ch.Blocked, tt.typ, tt.blocked)
+			}
+			if ch.Method != http.MethodPost || ch.PageURL != "https://www.example.com/products" {
+				t.Errorf("Method = %q, PageURL = %q", ch.Method, ch.PageURL)
+			}
+			u, err := url.Parse(ch.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Host != "geo.captcha-delivery.com" || u.Path != tt.path {
+				t.Errorf("URL = %q, want geo.captcha-delivery.com%s", ch.URL, tt.path)
+			}
+			for k, want := range tt.query {
+				if got := u.Query().Get(k); got != want {
+					t.Errorf("query %s = %q, want %q", k, got, want)
+				}
+			}
+
+			// The body is still readable by the caller.
+			body, _ := io.ReadAll(resp.Body)
+			if len(body) == 0 {
+				t.Error("body was not restored")
+			}
+		})
+	}
+}
+
+func TestParseDatadomeChallengeNone(t *testing.T) {
+	_, err := gatsbie.ParseDatadomeChallenge(blockedResponse(t, "none.html", http.MethodGet, "https://www.example.com/"))
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want INVALID_REQUEST", err)
+	}
+}
+
+func TestSolveDatadomeAuto(t *testing.T) {
+	t.Run("device check", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		resp, err := srv.Client().SolveDatadomeAuto(context.Background(),
+			blockedResponse(t, "interstitial.html", http.MethodGet, "https://www.example.com/products"), testProxy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.ExpectField(gatsbietest.TaskDatadome, "target_url", "https://www.example.com/products")
+		srv.ExpectField(gatsbietest.TaskDatadome, "target_method", "GET")
+		srv.ExpectField(gatsbietest.TaskDatadome, "proxy", testProxy)
+		if n := len(srv.Requests(gatsbietest.TaskDatadomeSlider)); n != 0 {
+			t.Errorf("slider called %d times", n)
+		}
+		if resp.Solution.Datadome != "test-datadome-cookie" {
+			t.Errorf("Datadome = %q", resp.Solution.Datadome)
+		}
+	})
+
+	t.Run("slider", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		srv.Handle(gatsbietest.TaskDatadomeSlider, gatsbietest.Solution(gatsbie.DatadomeSliderSolution{
+			Datadome:  "slider-cookie",
+			UserAgent: gatsbietest.DefaultUserAgent,
+		}).WithCost(0.002))
+		blocked := blockedResponse(t, "api.json", http.MethodPost, "https://www.example.com/api/cart")
+
+		resp, err := srv.Client().SolveDatadomeAuto(context.Background(), blocked, testProxy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The URL and method both describe the blocked request.
+		srv.ExpectField(gatsbietest.TaskDatadomeSlider, "target_url", "https://www.example.com/api/cart")
+		srv.ExpectField(gatsbietest.TaskDatadomeSlider, "target_method", "POST")
+		if n := len(srv.Requests(gatsbietest.TaskDatadome)); n != 0 {
+			t.Errorf("device check called %d times", n)
+		}
+		want := gatsbie.DatadomeSolution{Datadome: "slider-cookie", UserAgent: gatsbietest.DefaultUserAgent}
+		if resp.Solution != want {
+			t.Errorf("Solution = %+v, want %+v", resp.Solution, want)
+		}
+		if resp.Cost != 0.002 || resp.TaskID == "" || len(resp.Raw) == 0 {
+			t.Errorf("response metadata not carried over: %+v", resp)
+		}
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		_, err := srv.Client().SolveDatadomeAuto(context.Background(),
+			blockedResponse(t, "blocked.html", http.MethodGet, "https://www.example.com/"), testProxy)
+		var apiErr *gatsbie.APIError
+		if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+			t.Fatalf("err = %v, want INVALID_REQUEST", err)
+		}
+		if n := len(srv.Requests()); n != 0 {
+			t.Errorf("API called %d times for a banned IP", n)
+		}
+	})
+}
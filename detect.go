@@ -0,0 +1,65 @@
+package gatsbie
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/httpbody"
+)
+
+// Challenges lists the challenges DetectChallenges found on a page. A nil
+// field means that challenge was not found.
+type Challenges struct {
+	Turnstile  *TurnstileWidget
+	Funcaptcha *FuncaptchaParams
+	Castle     *CastleConfigJSON
+	Forter     *ForterPage
+	Akamai     *AkamaiPage
+	PerimeterX *PerimeterXBlock // press-and-hold block page
+
+	// CloudflareWAF is set if the page is a "Just a moment..." challenge.
+	// Without the status code it relies on the page alone; use
+	// IsCloudflareChallenge when the response is at hand.
+	CloudflareWAF bool
+}
+
+// DetectChallenges scans page, the HTML served at pageURL, for the
+// challenges this package can solve, and extracts what their SolveXxx
+// requests need.
+func DetectChallenges(page []byte, pageURL string) *Challenges {
+	var c Challenges
+	if w, ok := DetectTurnstile(page); ok {
+		c.Turnstile = &w
+	}
+	if p, ok := DetectFuncaptcha(page); ok {
+		c.Funcaptcha = &p
+	}
+	if cfg, ok := ExtractCastleConfig(page, pageURL); ok {
+		c.Castle = &cfg
+	}
+	if p, ok := DetectForter(page, pageURL); ok {
+		c.Forter = &p
+	}
+	if p := DetectAkamai(page, pageURL); p.BMP() || p.SBSD() || p.SecCPT {
+		c.Akamai = &p
+	}
+	if b, ok := ParsePerimeterXBlock(page, pageURL); ok {
+		c.PerimeterX = b
+	}
+	c.CloudflareWAF = cloudflareChallengePage.Match(page)
+	return &c
+}
+
+// readBlockedBody reads up to limit bytes of a blocked response's body and
+// replaces the body with what was read, so the caller can still read it.
+func readBlockedBody(resp *http.Response, limit int64) ([]byte, error) {
+	data, _, err := httpbody.ReadPrefix(resp.Body, limit)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocked response: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package gatsbie_test
+
+import (
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+func TestDetectChallenges(t *testing.T) {
+	tests := []struct {
+		fixture    []string
+		turnstile  bool
+		akamai     bool
+		perimeterx bool
+		cloudflare bool
+		castle     bool
+		forter     bool
+	}{
+		{[]string{"turnstile", "implicit.html"}, true, false, false, false, false, false},
+		{[]string{"akamai", "sbsd.html"}, false, true, false, false, false, false},
+		{[]string{"perimeterx", "block.html"}, false, false, true, false, false, false},
+		{[]string{"cloudflare", "just_a_moment.html"}, false, false, false, true, false, false},
+		{[]string{"cloudflare", "normal.html"}, false, false, false, false, false, false},
+		{[]string{"castle", "signup.html"}, false, false, false, false, true, false},
+		{[]string{"forter", "checkout.html"}, false, false, false, false, false, true},
+		{[]string{"akamai", "none.html"}, false, false, false, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture[len(tt.fixture)-1], func(t *testing.T) {
+			c := gatsbie.DetectChallenges(readFixture(t, tt.fixture...), "https://www.example.com/")
+			if (c.Turnstile != nil) != tt.turnstile {
+				t.Errorf("Turnstile = %+v, want found = %v", c.Turnstile, tt.turnstile)
+			}
+			if (c.Akamai != nil) != tt.akamai {
+				t.Errorf("Akamai = %+v, want found = %v", c.Akamai, tt.akamai)
+			}
+			if (c.PerimeterX != nil) != tt.perimeterx {
+				t.Errorf("PerimeterX = %+v, want found = %v", c.PerimeterX, tt.perimeterx)
+			}
+			if c.CloudflareWAF != tt.cloudflare {
+				t.Errorf("CloudflareWAF = %v, want %v", c.CloudflareWAF, tt.cloudflare)
+			}
+			if (c.Castle != nil) != tt.castle {
+				t.Errorf("Castle = %+v, want found = %v", c.Castle, tt.castle)
+			}
+			if (c.Forter != nil) != tt.forter {
+				t.Errorf("Forter = %+v, want found = %v", c.Forter, tt.forter)
+			}
+		})
+	}
+}
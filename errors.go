@@ -19,6 +19,10 @@ type APIError struct {
 	Details    string `json:"details,omitempty"`
 	Timestamp  int64  `json:"timestamp"`
 	HTTPStatus int    `json:"-"`
+
+	// IdempotencyKey is the key sent with the solve request that failed,
+	// for reconciling charges with the API.
+	IdempotencyKey string `json:"-"`
 }
 
 // Error implements the error interface.
@@ -59,6 +63,25 @@ func (e *APIError) IsInternalError() bool {
 	return e.Code == ErrCodeInternalError
 }
 
+// RequestError is returned when a solve request fails without an API
+// error response, such as on a network failure. The solve may still have
+// completed server-side, so IdempotencyKey identifies it for retries and
+// billing reconciliation.
+type RequestError struct {
+	IdempotencyKey string
+	Err            error
+}
+
+// Error implements the error interface.
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
 // errorResponse is the structure returned by the API on error.
 type errorResponse struct {
 	Success bool      `json:"success"`
@@ -0,0 +1,133 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestAPIErrorDecoding(t *testing.T) {
+	tests := []struct {
+		code   string
+		status int
+		is     func(*gatsbie.APIError) bool
+	}{
+		{gatsbie.ErrCodeAuthFailed, 401, (*gatsbie.APIError).IsAuthError},
+		{gatsbie.ErrCodeInsufficientCredits, 402, (*gatsbie.APIError).IsInsufficientCredits},
+		{gatsbie.ErrCodeInvalidRequest, 400, (*gatsbie.APIError).IsInvalidRequest},
+		{gatsbie.ErrCodeUpstreamError, 502, (*gatsbie.APIError).IsUpstreamError},
+		{gatsbie.ErrCodeSolveFailed, 422, (*gatsbie.APIError).IsSolveFailed},
+		{gatsbie.ErrCodeInternalError, 500, (*gatsbie.APIError).IsInternalError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Response{
+				Status: tt.status,
+				Body:   `{"success":false,"taskId":"task_1","error":{"code":"` + tt.code + `","message":"went wrong","details":"more","timestamp":1700000000000}}`,
+			})
+
+			_, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+			var apiErr *gatsbie.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v (%T), want *APIError", err, err)
+			}
+			if apiErr.Code != tt.code || apiErr.Message != "went wrong" || apiErr.Details != "more" {
+				t.Errorf("decoded %+v", apiErr)
+			}
+			if apiErr.Timestamp != 1700000000000 {
+				t.Errorf("Timestamp = %d", apiErr.Timestamp)
+			}
+			if apiErr.HTTPStatus != tt.status {
+				t.Errorf("HTTPStatus = %d, want %d", apiErr.HTTPStatus, tt.status)
+			}
+			if !tt.is(apiErr) {
+				t.Errorf("predicate for %s returned false", tt.code)
+			}
+			if apiErr.IdempotencyKey == "" {
+				t.Error("IdempotencyKey not set")
+			}
+			if want := "gatsbie: " + tt.code + ": went wrong (more)"; apiErr.Error() != want {
+				t.Errorf("Error() = %q, want %q", apiErr.Error(), want)
+			}
+		})
+	}
+}
+
+func TestNonJSONErrorResponse(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Malformed(403, "<html>Forbidden</html>"))
+
+	_, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var apiErr *gatsbie.APIError
+	if errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want a non-API error", err)
+	}
+	if !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("err = %v, want status in message", err)
+	}
+}
+
+func TestErrorEnvelopeWithoutError(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Response{Status: 400, Body: `{"success":false}`})
+
+	_, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err == nil || !strings.Contains(err.Error(), "unexpected error (status 400)") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestMalformedSuccessBody(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Response{Body: `{"success":true,"solution":`})
+
+	_, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err == nil || !strings.Contains(err.Error(), "failed to unmarshal response") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLargeErrorBodyIsTruncated(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	page := "<html>" + strings.Repeat("é", 100<<10) + "</html>"
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Malformed(502, page))
+
+	_, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	msg := err.Error()
+	if len(msg) > 1024 {
+		t.Errorf("error message is %d bytes, want it truncated", len(msg))
+	}
+	if !strings.HasSuffix(msg, "... (truncated)") {
+		t.Errorf("error message %q does not say it was truncated", msg[len(msg)-40:])
+	}
+	if !utf8.ValidString(msg) {
+		t.Error("error message was cut inside a UTF-8 sequence")
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: strings.Repeat("t", 4096)}))
+
+	_, err := srv.Client(gatsbie.WithMaxResponseSize(1024)).SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if !errors.Is(err, gatsbie.ErrResponseTooLarge) {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+
+	if _, err := srv.Client(gatsbie.WithMaxResponseSize(8192)).SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Errorf("response under the limit: %v", err)
+	}
+}
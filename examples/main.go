@@ -5,23 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
 )
 
 func main() {
-	// Get API key from environment
-	apiKey := os.Getenv("GATSBIE_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GATSBIE_API_KEY environment variable is required")
+	// Create client from the environment (GATSBIE_API_KEY, GATSBIE_TIMEOUT,
+	// GATSBIE_CONFIG, ...)
+	client, err := gatsbie.NewClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer client.Close()
 
-	// Create client with default options
-	client := gatsbie.NewClient(apiKey)
-
-	// Or with custom options
+	// Or with explicit options
 	// client := gatsbie.NewClient(apiKey,
 	// 	gatsbie.WithTimeout(60*time.Second),
 	// 	gatsbie.WithBaseURL("https://custom.api.url"),
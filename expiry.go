@@ -0,0 +1,221 @@
+package gatsbie
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Default solution lifetimes, used when the API does not say when a
+// solution expires. They are deliberately conservative: a cache that
+// refreshes early costs a solve, one that refreshes late costs a blocked
+// request.
+const (
+	RecaptchaLifetime     = 2 * time.Minute  // Google rejects tokens after two minutes
+	TurnstileLifetime     = 5 * time.Minute  // Cloudflare rejects tokens after 300 seconds
+	FuncaptchaLifetime    = 2 * time.Minute  // tokens are single use and short lived
+	CastleLifetime        = 2 * time.Minute  // request tokens are bound to a single action
+	ShapeLifetime         = time.Minute      // headers are generated for one request
+	CloudflareWAFLifetime = 30 * time.Minute // Cloudflare's default challenge passage, if cf_clearance has no expiry
+	AkamaiLifetime        = 30 * time.Minute // _abck lasts until the next sensor validation
+	SBSDLifetime          = 30 * time.Minute
+	DatadomeLifetime      = time.Hour
+	VercelLifetime        = time.Hour
+	PerimeterXLifetime    = 10 * time.Minute
+	CaptchaFoxLifetime    = 10 * time.Minute
+	Reese84Lifetime       = 15 * time.Minute
+	ForterLifetime        = 30 * time.Minute
+)
+
+// hasLifetime is implemented by solutions that have a default lifetime.
+type hasLifetime interface {
+	Lifetime() time.Duration
+}
+
+// hasCookieExpiry is implemented by solutions whose cookies can carry
+// their own expiry.
+type hasCookieExpiry interface {
+	cookieExpiry(receivedAt time.Time) (time.Time, bool)
+}
+
+// Lifetime returns DatadomeLifetime.
+func (DatadomeSolution) Lifetime() time.Duration { return DatadomeLifetime }
+
+// Lifetime returns RecaptchaLifetime.
+func (RecaptchaSolution) Lifetime() time.Duration { return RecaptchaLifetime }
+
+// Lifetime returns AkamaiLifetime.
+func (AkamaiSolution) Lifetime() time.Duration { return AkamaiLifetime }
+
+// Lifetime returns VercelLifetime.
+func (VercelSolution) Lifetime() time.Duration { return VercelLifetime }
+
+// Lifetime returns ShapeLifetime.
+func (ShapeSolution) Lifetime() time.Duration { return ShapeLifetime }
+
+// Lifetime returns ShapeLifetime.
+func (ShapeV2Solution) Lifetime() time.Duration { return ShapeLifetime }
+
+// Lifetime returns TurnstileLifetime.
+func (TurnstileSolution) Lifetime() time.Duration { return TurnstileLifetime }
+
+// Lifetime returns PerimeterXLifetime.
+func (PerimeterXSolution) Lifetime() time.Duration { return PerimeterXLifetime }
+
+// Lifetime returns PerimeterXLifetime.
+func (PerimeterXHoldSolution) Lifetime() time.Duration { return PerimeterXLifetime }
+
+// Lifetime returns CloudflareWAFLifetime.
+func (CloudflareWAFSolution) Lifetime() time.Duration { return CloudflareWAFLifetime }
+
+// cookieExpiry returns the expiry of cf_clearance from its Max-Age or
+// Expires attribute, if the API returned them.
+func (s CloudflareWAFSolution) cookieExpiry(receivedAt time.Time) (time.Time, bool) {
+	c := s.Cookies
+	switch {
+	case c.ClearanceMaxAge > 0:
+		return receivedAt.Add(time.Duration(c.ClearanceMaxAge) * time.Second), true
+	case c.ClearanceMaxAge < 0:
+		// Max-Age=0 deletes the cookie.
+		return receivedAt, true
+	case !c.ClearanceExpires.IsZero():
+		return c.ClearanceExpires, true
+	}
+	return time.Time{}, false
+}
+
+// Lifetime returns DatadomeLifetime.
+func (DatadomeSliderSolution) Lifetime() time.Duration { return DatadomeLifetime }
+
+// Lifetime returns CaptchaFoxLifetime.
+func (CaptchaFoxSolution) Lifetime() time.Duration { return CaptchaFoxLifetime }
+
+// Lifetime returns CastleLifetime.
+func (CastleSolution) Lifetime() time.Duration { return CastleLifetime }
+
+// Lifetime returns Reese84Lifetime.
+func (Reese84Solution) Lifetime() time.Duration { return Reese84Lifetime }
+
+// Lifetime returns ForterLifetime.
+func (ForterSolution) Lifetime() time.Duration { return ForterLifetime }
+
+// Lifetime returns FuncaptchaLifetime.
+func (FuncaptchaSolution) Lifetime() time.Duration { return FuncaptchaLifetime }
+
+// Lifetime returns SBSDLifetime.
+func (SBSDSolution) Lifetime() time.Duration { return SBSDLifetime }
+
+// received is implemented by responses that record when they arrived.
+type received interface {
+	setReceivedAt(t time.Time)
+}
+
+func (r *SolveResponse[T]) setReceivedAt(t time.Time) {
+	r.ReceivedAt = t
+}
+
+// Members that carry an expiry, checked in the solution and then in the
+// response body. Absolute times are RFC 3339 strings or Unix timestamps in
+// seconds or milliseconds; relative ones are seconds from ReceivedAt.
+var (
+	absoluteExpiryKeys = []string{"expiresAt", "expires_at"}
+	relativeExpiryKeys = []string{"expiresIn", "expires_in", "ttl", "renewInSec"}
+)
+
+// ExpiresAt returns when the solution stops being usable. It uses the
+// expiry reported by the API if there is one, then the expiry of the
+// solution's cookie, such as cf_clearance, and otherwise adds the
+// solution type's default Lifetime to ReceivedAt. A solution type without
+// a Lifetime is treated as expiring on receipt.
+func (r *SolveResponse[T]) ExpiresAt() time.Time {
+	for _, raw := range []json.RawMessage{r.RawSolution, r.Raw} {
+		if t, ok := r.serverExpiry(raw); ok {
+			return t
+		}
+	}
+	if c, ok := any(r.Solution).(hasCookieExpiry); ok {
+		if t, ok := c.cookieExpiry(r.ReceivedAt); ok {
+			return t
+		}
+	}
+	if l, ok := any(r.Solution).(hasLifetime); ok {
+		return r.ReceivedAt.Add(l.Lifetime())
+	}
+	return r.ReceivedAt
+}
+
+// IsExpired reports whether the solution has expired.
+func (r *SolveResponse[T]) IsExpired() bool {
+	return !time.Now().Before(r.ExpiresAt())
+}
+
+// TimeLeft returns how long the solution remains usable, or zero if it
+// has expired.
+func (r *SolveResponse[T]) TimeLeft() time.Duration {
+	if d := time.Until(r.ExpiresAt()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// serverExpiry looks for an expiry among the members of the JSON object
+// raw.
+func (r *SolveResponse[T]) serverExpiry(raw json.RawMessage) (time.Time, bool) {
+	if len(raw) == 0 {
+		return time.Time{}, false
+	}
+	var members map[string]json.RawMessage
+	if json.Unmarshal(raw, &members) != nil {
+		return time.Time{}, false
+	}
+	for _, key := range absoluteExpiryKeys {
+		if t, ok := parseExpiry(members[key]); ok {
+			return t, true
+		}
+	}
+	for _, key := range relativeExpiryKeys {
+		if secs, ok := parseNumber(members[key]); ok && secs > 0 {
+			return r.ReceivedAt.Add(time.Duration(secs * float64(time.Second))), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseExpiry parses an RFC 3339 string or a Unix timestamp.
+func parseExpiry(raw json.RawMessage) (time.Time, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, true
+		}
+	}
+	n, ok := parseNumber(raw)
+	if !ok || n <= 0 {
+		return time.Time{}, false
+	}
+	// Timestamps past 1e12 are in milliseconds; that is the year 33658 in
+	// seconds.
+	if n > 1e12 {
+		return time.UnixMilli(int64(n)), true
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+// parseNumber parses a JSON number or a numeric string.
+func parseNumber(raw json.RawMessage) (float64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var n float64
+	if json.Unmarshal(raw, &n) == nil {
+		return n, true
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
@@ -0,0 +1,192 @@
+package gatsbie_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestExpiresAt(t *testing.T) {
+	received := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		body string
+		want time.Time
+	}{
+		{
+			name: "default lifetime",
+			body: `{"success":true,"solution":{"token":"t"}}`,
+			want: received.Add(gatsbie.RecaptchaLifetime),
+		},
+		{
+			name: "RFC 3339 in solution",
+			body: `{"success":true,"solution":{"token":"t","expiresAt":"2024-03-01T12:00:30Z"}}`,
+			want: received.Add(30 * time.Second),
+		},
+		{
+			name: "Unix seconds in body",
+			body: `{"success":true,"solution":{"token":"t"},"expires_at":1709294460}`,
+			want: time.Unix(1709294460, 0),
+		},
+		{
+			name: "Unix milliseconds",
+			body: `{"success":true,"solution":{"token":"t","expires_at":1709294460500}}`,
+			want: time.UnixMilli(1709294460500),
+		},
+		{
+			name: "relative seconds",
+			body: `{"success":true,"solution":{"token":"t","ttl":"45"}}`,
+			want: received.Add(45 * time.Second),
+		},
+		{
+			name: "unparseable expiry falls back",
+			body: `{"success":true,"solution":{"token":"t","expires_at":"soon"}}`,
+			want: received.Add(gatsbie.RecaptchaLifetime),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp gatsbie.SolveResponse[gatsbie.RecaptchaSolution]
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			resp.ReceivedAt = received
+			if got := resp.ExpiresAt(); !got.Equal(tt.want) {
+				t.Errorf("ExpiresAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudflareWAFExpiresAt(t *testing.T) {
+	received := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		clearance string
+		want      time.Time
+	}{
+		{
+			name:      "bare value",
+			clearance: "abc",
+			want:      received.Add(gatsbie.CloudflareWAFLifetime),
+		},
+		{
+			name:      "Max-Age",
+			clearance: "abc; Path=/; Max-Age=1800; Domain=.example.com; Secure",
+			want:      received.Add(30 * time.Minute),
+		},
+		{
+			name:      "Expires",
+			clearance: "cf_clearance=abc; Expires=Fri, 01 Mar 2024 13:00:00 GMT; HttpOnly",
+			want:      received.Add(time.Hour),
+		},
+		{
+			name:      "Max-Age wins over Expires",
+			clearance: "abc; Expires=Fri, 01 Mar 2024 13:00:00 GMT; Max-Age=600",
+			want:      received.Add(10 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"success":true,"solution":{"cookies":{"cf_clearance":"` + tt.clearance + `"},"ua":"ua"}}`
+			var resp gatsbie.SolveResponse[gatsbie.CloudflareWAFSolution]
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			resp.ReceivedAt = received
+			if got := resp.ExpiresAt(); !got.Equal(tt.want) {
+				t.Errorf("ExpiresAt() = %v, want %v", got, tt.want)
+			}
+			if got := resp.Solution.Cookies.CfClearance; got != "abc" {
+				t.Errorf("CfClearance = %q, want abc", got)
+			}
+		})
+	}
+}
+
+func TestCloudflareWAFServerExpiryWins(t *testing.T) {
+	body := `{"success":true,"solution":{"cookies":{"cf_clearance":"abc; Max-Age=600"},"ua":"ua","expires_in":60}}`
+	var resp gatsbie.SolveResponse[gatsbie.CloudflareWAFSolution]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.ExpiresAt(), resp.ReceivedAt.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("ExpiresAt() = %v, want %v", got, want)
+	}
+}
+
+func TestTimeLeft(t *testing.T) {
+	var resp gatsbie.SolveResponse[gatsbie.TurnstileSolution]
+	if err := json.Unmarshal([]byte(`{"success":true,"solution":{"token":"t"}}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// An API body decoded outside a Client has no receipt time.
+	if !resp.IsExpired() {
+		t.Error("solution without ReceivedAt is not expired")
+	}
+
+	resp.ReceivedAt = time.Now()
+	if resp.IsExpired() {
+		t.Error("fresh solution is expired")
+	}
+	if left := resp.TimeLeft(); left <= gatsbie.TurnstileLifetime-time.Minute || left > gatsbie.TurnstileLifetime {
+		t.Errorf("TimeLeft() = %v, want about %v", left, gatsbie.TurnstileLifetime)
+	}
+
+	resp.ReceivedAt = time.Now().Add(-gatsbie.TurnstileLifetime)
+	if !resp.IsExpired() {
+		t.Error("solution past its lifetime is not expired")
+	}
+	if left := resp.TimeLeft(); left != 0 {
+		t.Errorf("TimeLeft() = %v, want 0", left)
+	}
+}
+
+func TestReceivedAtRoundTrips(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	before := time.Now()
+	resp, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReceivedAt.Before(before) || resp.ReceivedAt.After(time.Now()) {
+		t.Fatalf("ReceivedAt = %v, want the time of the call", resp.ReceivedAt)
+	}
+
+	// A cached response keeps its age.
+	resp.ReceivedAt = resp.ReceivedAt.Add(-gatsbie.TurnstileLifetime)
+	data, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached gatsbie.SolveResponse[gatsbie.TurnstileSolution]
+	if err := json.Unmarshal(data, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if !cached.ReceivedAt.Equal(resp.ReceivedAt) {
+		t.Errorf("ReceivedAt = %v after a round trip, want %v", cached.ReceivedAt, resp.ReceivedAt)
+	}
+	if !cached.IsExpired() {
+		t.Error("cached response past its lifetime is not expired")
+	}
+}
+
+func TestEverySolutionHasLifetime(t *testing.T) {
+	solutions := []interface{ Lifetime() time.Duration }{
+		gatsbie.DatadomeSolution{}, gatsbie.RecaptchaSolution{}, gatsbie.AkamaiSolution{},
+		gatsbie.VercelSolution{}, gatsbie.ShapeSolution{}, gatsbie.ShapeV2Solution{},
+		gatsbie.TurnstileSolution{}, gatsbie.PerimeterXSolution{}, gatsbie.CloudflareWAFSolution{},
+		gatsbie.DatadomeSliderSolution{}, gatsbie.CaptchaFoxSolution{}, gatsbie.CastleSolution{},
+		gatsbie.Reese84Solution{}, gatsbie.ForterSolution{}, gatsbie.FuncaptchaSolution{},
+		gatsbie.SBSDSolution{}, gatsbie.PerimeterXHoldSolution{},
+	}
+	for _, s := range solutions {
+		if s.Lifetime() <= 0 {
+			t.Errorf("%T.Lifetime() = %v", s, s.Lifetime())
+		}
+	}
+}
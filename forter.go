@@ -0,0 +1,229 @@
+package gatsbie
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultForterCookie is the cookie most merchants read the Forter token
+// from.
+const DefaultForterCookie = "forterToken"
+
+var (
+	forterScriptURL = regexp.MustCompile(`(?:https?:)?//[A-Za-z0-9.-]*\.forter\.com/[^"'\s<>]*\.js`)
+	forterSiteIDVar = regexp.MustCompile(`(?i)\bsite_?id["']?\s*[=:]\s*["']([0-9a-f]{6,})["']`)
+	forterSiteIDSn  = regexp.MustCompile(`\.forter\.com/sn/([0-9a-f]{6,})/`)
+	forterSiteIDSub = regexp.MustCompile(`//([0-9a-f]{6,})\.cdn\d*\.forter\.com/`)
+)
+
+// ForterPage is the Forter integration found on a page.
+type ForterPage struct {
+	SiteID string
+	JSURL  string
+}
+
+// Request returns a ForterRequest for the page.
+func (p ForterPage) Request(proxy, targetURL string) *ForterRequest {
+	return &ForterRequest{Proxy: proxy, TargetURL: targetURL, ForterJsUrl: p.JSURL, SiteID: p.SiteID}
+}
+
+// DetectForter finds the Forter site ID and script URL on page, the HTML
+// served at pageURL. The site ID is read from the snippet's siteId
+// variable or from the script URL; when the snippet builds the URL at run
+// time, the standard https://<site>.cdn4.forter.com/sn/<site>/script.js is
+// returned. The siteId variable is only trusted on a page that refers to
+// forter.com, since many other scripts have one. It reports false if no
+// site ID was found.
+func DetectForter(page []byte, pageURL string) (ForterPage, bool) {
+	if !bytes.Contains(page, []byte("forter.com")) {
+		return ForterPage{}, false
+	}
+	var p ForterPage
+	if m := forterScriptURL.Find(page); m != nil {
+		p.JSURL = resolveURL(pageURL, unescapeJSString(string(m)))
+	}
+	switch {
+	case forterSiteIDVar.Match(page):
+		p.SiteID = string(forterSiteIDVar.FindSubmatch(page)[1])
+	case forterSiteIDSn.MatchString(p.JSURL):
+		p.SiteID = forterSiteIDSn.FindStringSubmatch(p.JSURL)[1]
+	case forterSiteIDSub.MatchString(p.JSURL):
+		p.SiteID = forterSiteIDSub.FindStringSubmatch(p.JSURL)[1]
+	default:
+		return ForterPage{}, false
+	}
+	if p.JSURL == "" {
+		p.JSURL = "https://" + p.SiteID + ".cdn4.forter.com/sn/" + p.SiteID + "/script.js"
+	}
+	return p, true
+}
+
+// resolveURL resolves ref against base, returning ref unchanged if either
+// does not parse.
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(u).String()
+}
+
+// ForterPlacement says where a merchant reads the Forter token from. The
+// zero value sends it as the forterToken cookie.
+type ForterPlacement struct {
+	// Header, if set, sends the token in this request header instead of
+	// a cookie.
+	Header string
+
+	// CookieName is the cookie name, DefaultForterCookie if empty, and
+	// Domain the domain Cookie sets it on, such as ".example.com".
+	CookieName string
+	Domain     string
+}
+
+// Cookie returns token as the merchant's Forter cookie.
+func (p ForterPlacement) Cookie(token string) *http.Cookie {
+	name := p.CookieName
+	if name == "" {
+		name = DefaultForterCookie
+	}
+	return &http.Cookie{Name: name, Value: token, Path: "/", Domain: p.Domain}
+}
+
+// Apply sets token on req in the header or cookie the merchant expects.
+func (p ForterPlacement) Apply(req *http.Request, token string) {
+	if p.Header != "" {
+		req.Header.Set(p.Header, token)
+		return
+	}
+	c := p.Cookie(token)
+	req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+}
+
+// ForterSession hands out a Forter token for a checkout, solving again
+// once the token is older than its maximum age. It is safe for concurrent
+// use; concurrent callers share one solve.
+type ForterSession struct {
+	client    *Client
+	req       ForterRequest
+	placement ForterPlacement
+	maxAge    time.Duration
+	opts      []CallOption
+
+	mu       sync.Mutex
+	resp     *SolveResponse[ForterSolution]
+	solves   int          // successful solves, numbering the next for attemptContext
+	inflight *forterSolve // the solve in progress, if any
+}
+
+// forterSolve is a solve shared by the callers that wait for it.
+type forterSolve struct {
+	done chan struct{}
+	resp *SolveResponse[ForterSolution]
+	err  error
+}
+
+// NewForterSession returns a session that solves req on first use and
+// again whenever the token is older than maxAge or has expired. A maxAge
+// of zero uses ForterLifetime.
+func (c *Client) NewForterSession(req *ForterRequest, placement ForterPlacement, maxAge time.Duration, opts ...CallOption) *ForterSession {
+	if maxAge <= 0 {
+		maxAge = ForterLifetime
+	}
+	return &ForterSession{
+		client:    c,
+		req:       *req,
+		placement: placement,
+		maxAge:    maxAge,
+		opts:      opts,
+	}
+}
+
+// Solve returns the current token, solving a new one if there is none or
+// it is stale. A key set on ctx with WithIdempotencyKey is used for the
+// first solve and suffixed with -2, -3, ... for each re-solve, so a
+// rejected token is never replayed; a solve that failed is retried with
+// the same key. The session is not locked while solving, and callers that
+// arrive meanwhile wait for the same solve.
+func (s *ForterSession) Solve(ctx context.Context) (*SolveResponse[ForterSolution], error) {
+	s.mu.Lock()
+	if s.resp != nil && !s.stale(s.resp) {
+		resp := s.resp
+		s.mu.Unlock()
+		return resp, nil
+	}
+	if call := s.inflight; call != nil {
+		s.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.resp, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &forterSolve{done: make(chan struct{})}
+	s.inflight = call
+	n := s.solves + 1
+	s.mu.Unlock()
+
+	req := s.req
+	call.resp, call.err = s.client.SolveForter(attemptContext(ctx, n), &req, s.opts...)
+
+	s.mu.Lock()
+	s.inflight = nil
+	if call.err == nil {
+		s.resp = call.resp
+		s.solves = n
+	}
+	s.mu.Unlock()
+	close(call.done)
+	return call.resp, call.err
+}
+
+// Apply sets a fresh token on req where the merchant expects it, and the
+// User-Agent it was solved with if req has none.
+func (s *ForterSession) Apply(ctx context.Context, req *http.Request) error {
+	resp, err := s.Solve(ctx)
+	if err != nil {
+		return err
+	}
+	s.placement.Apply(req, resp.Solution.Token)
+	if req.Header.Get("User-Agent") == "" && resp.Solution.UserAgent != "" {
+		req.Header.Set("User-Agent", resp.Solution.UserAgent)
+	}
+	return nil
+}
+
+// Cookie returns the current token as the merchant's Forter cookie, or
+// nil if there is none. It does not solve.
+func (s *ForterSession) Cookie() *http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resp == nil {
+		return nil
+	}
+	return s.placement.Cookie(s.resp.Solution.Token)
+}
+
+// Invalidate discards the current token, so the next call solves again.
+// Call it when the merchant rejects the token.
+func (s *ForterSession) Invalidate() {
+	s.mu.Lock()
+	s.resp = nil
+	s.mu.Unlock()
+}
+
+// stale reports whether resp is older than the session's maximum age or
+// has expired.
+func (s *ForterSession) stale(resp *SolveResponse[ForterSolution]) bool {
+	return time.Since(resp.ReceivedAt) >= s.maxAge || resp.IsExpired()
+}
@@ -0,0 +1,226 @@
+package gatsbie_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestDetectForter(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    gatsbie.ForterPage
+	}{
+		{
+			fixture: "checkout.html",
+			want: gatsbie.ForterPage{
+				SiteID: "9f3c2a1b7e4d",
+				JSURL:  "https://9f3c2a1b7e4d.cdn4.forter.com/sn/9f3c2a1b7e4d/script.js",
+			},
+		},
+		{
+			fixture: "script_tag.html",
+			want: gatsbie.ForterPage{
+				SiteID: "4b8e21d0c7aa",
+				JSURL:  "https://cdn0.forter.com/sn/4b8e21d0c7aa/script.js",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, ok := gatsbie.DetectForter(readFixture(t, "forter", tt.fixture), "https://www.example.com/checkout")
+			if !ok || got != tt.want {
+				t.Errorf("DetectForter = %+v, %v\nwant %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if got, ok := gatsbie.DetectForter(readFixture(t, "akamai", "none.html"), "https://www.example.com/"); ok {
+		t.Errorf("DetectForter found %+v on a page without Forter", got)
+	}
+	// Other scripts have a hex siteId too.
+	page := []byte(`<script>var analytics = { siteId: "5e8f2a9c1d3b" };</script><script src="https://cdn.analytics.example/a.js"></script>`)
+	if got, ok := gatsbie.DetectForter(page, "https://www.example.com/"); ok {
+		t.Errorf("DetectForter found %+v from a siteId without forter.com", got)
+	}
+}
+
+func TestForterPlacement(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement gatsbie.ForterPlacement
+		header    string
+		cookie    string
+	}{
+		{"default", gatsbie.ForterPlacement{}, "", "forterToken"},
+		{"cookie", gatsbie.ForterPlacement{CookieName: "ftr_token", Domain: ".example.com"}, "", "ftr_token"},
+		{"header", gatsbie.ForterPlacement{Header: "X-Forter-Token"}, "X-Forter-Token", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "https://www.example.com/checkout", nil)
+			tt.placement.Apply(req, "tok")
+			if tt.header != "" && req.Header.Get(tt.header) != "tok" {
+				t.Errorf("header %s = %q, want tok", tt.header, req.Header.Get(tt.header))
+			}
+			c, err := req.Cookie(tt.cookie)
+			if tt.cookie == "" {
+				if len(req.Cookies()) != 0 {
+					t.Errorf("cookies = %v, want none", req.Cookies())
+				}
+				return
+			}
+			if err != nil || c.Value != "tok" {
+				t.Errorf("cookie %s = %v, %v", tt.cookie, c, err)
+			}
+		})
+	}
+
+	c := gatsbie.ForterPlacement{CookieName: "ftr_token", Domain: ".example.com"}.Cookie("tok")
+	if c.Name != "ftr_token" || c.Domain != ".example.com" || c.Path != "/" {
+		t.Errorf("Cookie() = %+v", c)
+	}
+}
+
+func TestForterSession(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	page, _ := gatsbie.DetectForter(readFixture(t, "forter", "checkout.html"), "https://www.example.com/checkout")
+	s := srv.Client().NewForterSession(page.Request(testProxy, "https://www.example.com/checkout"),
+		gatsbie.ForterPlacement{Header: "X-Forter-Token"}, 50*time.Millisecond)
+
+	if s.Cookie() != nil {
+		t.Error("Cookie() before the first solve is not nil")
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPost, "https://www.example.com/checkout", nil)
+		if err := s.Apply(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("X-Forter-Token") == "" {
+			t.Fatal("token not applied")
+		}
+		if req.Header.Get("User-Agent") != gatsbietest.DefaultUserAgent {
+			t.Errorf("User-Agent = %q", req.Header.Get("User-Agent"))
+		}
+	}
+	if n := len(srv.Requests(gatsbietest.TaskForter)); n != 1 {
+		t.Fatalf("solved %d times for a fresh token, want 1", n)
+	}
+	srv.ExpectField(gatsbietest.TaskForter, "site_id", "9f3c2a1b7e4d")
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := s.Solve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(gatsbietest.TaskForter)); n != 2 {
+		t.Fatalf("solved %d times after maxAge, want 2", n)
+	}
+
+	s.Invalidate()
+	if _, err := s.Solve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Requests(gatsbietest.TaskForter)); n != 3 {
+		t.Fatalf("solved %d times after Invalidate, want 3", n)
+	}
+}
+
+func TestForterSessionIdempotencyKeys(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskForter,
+		gatsbietest.Solution(gatsbie.ForterSolution{Token: "rejected", UserAgent: gatsbietest.DefaultUserAgent}),
+		gatsbietest.Solution(gatsbie.ForterSolution{Token: "fresh", UserAgent: gatsbietest.DefaultUserAgent}))
+	s := srv.Client().NewForterSession(&gatsbie.ForterRequest{SiteID: "9f3c2a1b7e4d"}, gatsbie.ForterPlacement{}, 0)
+
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "checkout-9")
+	if _, err := s.Solve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Invalidate()
+	resp, err := s.Solve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Solution.Token != "fresh" {
+		t.Errorf("token after Invalidate = %q, want fresh", resp.Solution.Token)
+	}
+
+	reqs := srv.Requests(gatsbietest.TaskForter)
+	if len(reqs) != 2 {
+		t.Fatalf("solved %d times, want 2", len(reqs))
+	}
+	if got := reqs[0].Header.Get("Idempotency-Key"); got != "checkout-9" {
+		t.Errorf("first key = %q, want checkout-9", got)
+	}
+	if got := reqs[1].Header.Get("Idempotency-Key"); got != "checkout-9-2" {
+		t.Errorf("key after Invalidate = %q, want checkout-9-2", got)
+	}
+}
+
+func TestForterSessionRetryReusesKey(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskForter,
+		gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "try again"),
+		gatsbietest.Solution(gatsbie.ForterSolution{Token: "tok", UserAgent: gatsbietest.DefaultUserAgent}))
+	s := srv.Client().NewForterSession(&gatsbie.ForterRequest{SiteID: "9f3c2a1b7e4d"}, gatsbie.ForterPlacement{}, 0)
+
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "checkout-9")
+	if _, err := s.Solve(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := s.Solve(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range srv.Requests(gatsbietest.TaskForter) {
+		if got := r.Header.Get("Idempotency-Key"); got != "checkout-9" {
+			t.Errorf("request %d key = %q, want checkout-9 for a retry", i, got)
+		}
+	}
+}
+
+func TestForterSessionUnlockedWhileSolving(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskForter, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskForter)).WithDelay(200*time.Millisecond))
+	s := srv.Client().NewForterSession(&gatsbie.ForterRequest{SiteID: "9f3c2a1b7e4d"}, gatsbie.ForterPlacement{}, 0)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.Solve(context.Background())
+		}(i)
+	}
+	for len(srv.Requests(gatsbietest.TaskForter)) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if s.Cookie() != nil {
+		t.Error("Cookie() during the first solve is not nil")
+	}
+	s.Invalidate()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Cookie and Invalidate blocked for %v during a solve", elapsed)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(srv.Requests(gatsbietest.TaskForter)); n != 1 {
+		t.Errorf("solved %d times for concurrent callers, want 1", n)
+	}
+	if s.Cookie() == nil {
+		t.Error("Cookie() after the solve is nil")
+	}
+}
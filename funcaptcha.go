@@ -0,0 +1,111 @@
+package gatsbie
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// FuncaptchaParams are the Arkose Labs parameters of a Funcaptcha found on
+// a page or in an fc/gt2 call.
+type FuncaptchaParams struct {
+	PublicKey string
+	APIHost   string // such as client-api.arkoselabs.com
+	Blob      string
+	Language  string
+}
+
+// Request returns a FuncaptchaRequest for the parameters.
+func (p FuncaptchaParams) Request(proxy, targetURL string) *FuncaptchaRequest {
+	return &FuncaptchaRequest{
+		Proxy:         proxy,
+		TargetURL:     targetURL,
+		CustomApiHost: p.APIHost,
+		PublicKey:     p.PublicKey,
+		Blob:          p.Blob,
+		Language:      p.Language,
+	}
+}
+
+const arkosePublicKey = `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`
+
+var (
+	arkoseScript = regexp.MustCompile(`https://([a-z0-9.-]+)/v2/(` + arkosePublicKey + `)/api\.js`)
+	arkoseKey    = regexp.MustCompile(`(?:data-pkey|publicKey|public_key|"pk")["']?\s*[:=]\s*["'](` + arkosePublicKey + `)["']`)
+	arkoseBlob   = regexp.MustCompile(`(?:data\[blob\]|blob)["']?\s*[:=]\s*["']([^"']+)["']`)
+	arkoseLang   = regexp.MustCompile(`(?:data-language|data\[language\])["']?\s*[:=]\s*["']([\w-]+)["']`)
+)
+
+// DetectFuncaptcha finds the Arkose public key, API host, blob and
+// language in page. It reports false if no public key was found.
+func DetectFuncaptcha(page []byte) (FuncaptchaParams, bool) {
+	var p FuncaptchaParams
+	if m := arkoseScript.FindSubmatch(page); m != nil {
+		p.APIHost, p.PublicKey = string(m[1]), string(m[2])
+	} else if m := arkoseKey.FindSubmatch(page); m != nil {
+		p.PublicKey = string(m[1])
+	}
+	if p.PublicKey == "" {
+		return FuncaptchaParams{}, false
+	}
+	if m := arkoseBlob.FindSubmatch(page); m != nil {
+		p.Blob = unescapeJSString(string(m[1]))
+	}
+	if m := arkoseLang.FindSubmatch(page); m != nil {
+		p.Language = string(m[1])
+	}
+	return p, true
+}
+
+// ParseFuncaptchaGT2 extracts the parameters from an Arkose fc/gt2 call.
+// body is either the JSON response, whose token carries the public key
+// (pk) and API host (surl), or the form-encoded request, which carries
+// public_key, data[blob] and language. It reports false if neither
+// yields a public key.
+func ParseFuncaptchaGT2(body []byte) (FuncaptchaParams, bool) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Token != "" {
+		var p FuncaptchaParams
+		for _, field := range strings.Split(resp.Token, "|") {
+			name, value, _ := strings.Cut(field, "=")
+			switch name {
+			case "pk":
+				p.PublicKey = value
+			case "surl":
+				if v, err := url.QueryUnescape(value); err == nil {
+					value = v
+				}
+				if u, err := url.Parse(value); err == nil {
+					p.APIHost = u.Host
+				}
+			}
+		}
+		return p, p.PublicKey != ""
+	}
+
+	form, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil || form.Get("public_key") == "" {
+		return FuncaptchaParams{}, false
+	}
+	return FuncaptchaParams{
+		PublicKey: form.Get("public_key"),
+		Blob:      form.Get("data[blob]"),
+		Language:  form.Get("language"),
+	}, true
+}
+
+// unescapeJSString undoes the escapes a blob picks up when embedded in a
+// JSON or JavaScript string.
+func unescapeJSString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var out string
+	if json.Unmarshal([]byte(`"`+s+`"`), &out) == nil {
+		return out
+	}
+	return s
+}
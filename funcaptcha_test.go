@@ -0,0 +1,93 @@
+package gatsbie_test
+
+import (
+	"context"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+const signupPublicKey = "476068BF-9607-4799-B53D-966BE91B2B04"
+
+func TestDetectFuncaptcha(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    gatsbie.FuncaptchaParams
+	}{
+		{"signup.html", gatsbie.FuncaptchaParams{
+			PublicKey: signupPublicKey,
+			APIHost:   "example-api.arkoselabs.com",
+			Blob:      "eyJkYXRhIjoiQUJDMTIz/Kzc9PSJ9",
+			Language:  "de",
+		}},
+		{"login.html", gatsbie.FuncaptchaParams{
+			PublicKey: "2CB16598-CB82-4CF7-B332-5990DB66F3AB",
+			Blob:      "Zm9vYmFyLWxvZ2luLWJsb2I=",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, ok := gatsbie.DetectFuncaptcha(readFixture(t, "funcaptcha", tt.fixture))
+			if !ok || got != tt.want {
+				t.Errorf("DetectFuncaptcha = %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := gatsbie.DetectFuncaptcha(readFixture(t, "turnstile", "implicit.html")); ok {
+		t.Error("DetectFuncaptcha found Arkose on a page without it")
+	}
+}
+
+func TestParseFuncaptchaGT2(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    gatsbie.FuncaptchaParams
+	}{
+		{"gt2_response.json", gatsbie.FuncaptchaParams{
+			PublicKey: signupPublicKey,
+			APIHost:   "example-api.arkoselabs.com",
+		}},
+		{"gt2_request.txt", gatsbie.FuncaptchaParams{
+			PublicKey: signupPublicKey,
+			Blob:      "eyJkYXRhIjoiQUJDMTIz/Kzc9PSJ9",
+			Language:  "de",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, ok := gatsbie.ParseFuncaptchaGT2(readFixture(t, "funcaptcha", tt.fixture))
+			if !ok || got != tt.want {
+				t.Errorf("ParseFuncaptchaGT2 = %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := gatsbie.ParseFuncaptchaGT2([]byte(`{"error":"DENIED ACCESS"}`)); ok {
+		t.Error("ParseFuncaptchaGT2 accepted a response without a token")
+	}
+}
+
+func TestFuncaptchaOptionalFields(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	p, _ := gatsbie.DetectFuncaptcha(readFixture(t, "funcaptcha", "signup.html"))
+	req := p.Request(testProxy, "https://www.example.com/signup")
+	req.ExtraData = map[string]string{"id": "customer-42"}
+	if _, err := srv.Client().SolveFuncaptcha(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskFuncaptcha, "public_key", signupPublicKey)
+	srv.ExpectField(gatsbietest.TaskFuncaptcha, "custom_api_host", "example-api.arkoselabs.com")
+	srv.ExpectField(gatsbietest.TaskFuncaptcha, "blob", "eyJkYXRhIjoiQUJDMTIz/Kzc9PSJ9")
+	srv.ExpectField(gatsbietest.TaskFuncaptcha, "language", "de")
+	srv.ExpectField(gatsbietest.TaskFuncaptcha, "extra_data.id", "customer-42")
+
+	srv = gatsbietest.NewServer(t)
+	if _, err := srv.Client().SolveFuncaptcha(context.Background(), &gatsbie.FuncaptchaRequest{PublicKey: signupPublicKey}); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"blob", "language", "extra_data"} {
+		srv.ExpectNoField(gatsbietest.TaskFuncaptcha, field)
+	}
+}
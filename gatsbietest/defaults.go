@@ -0,0 +1,82 @@
+package gatsbietest
+
+import gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+
+// DefaultUserAgent is the user agent returned in default solutions.
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+
+// DefaultSolution returns the canned solution a Server sends for task when
+// no response has been scripted.
+func DefaultSolution(task Task) any {
+	switch task {
+	case TaskHealth:
+		return gatsbie.HealthResponse{Status: "ok"}
+	case TaskDatadome:
+		return gatsbie.DatadomeSolution{Datadome: "test-datadome-cookie", UserAgent: DefaultUserAgent}
+	case TaskRecaptcha, TaskRecaptchaEnterprise:
+		return gatsbie.RecaptchaSolution{Token: "test-recaptcha-token"}
+	case TaskAkamai:
+		return gatsbie.AkamaiSolution{
+			CookiesDict: gatsbie.AkamaiCookies{
+				Abck: "test-abck~0~test",
+				BmSz: "test-bm-sz",
+			},
+			UserAgent: DefaultUserAgent,
+		}
+	case TaskVercel:
+		return gatsbie.VercelSolution{Vcrcs: "test-vcrcs", UserAgent: DefaultUserAgent}
+	case TaskShape:
+		return gatsbie.ShapeSolution{"x-test-a": "test-header-a", "x-test-b": "test-header-b"}
+	case TaskShapeV2:
+		return gatsbie.ShapeV2Solution{Headers: map[string]string{"x-test-a": "test-header-a"}}
+	case TaskTurnstile:
+		return gatsbie.TurnstileSolution{Token: "test-turnstile-token", UserAgent: DefaultUserAgent}
+	case TaskPerimeterX:
+		return gatsbie.PerimeterXSolution{
+			Cookies: gatsbie.PerimeterXCookies{
+				Px3:   "test-px3",
+				Pxde:  "test-pxde",
+				Pxvid: "test-pxvid",
+				Pxcts: "test-pxcts",
+			},
+			UserAgent: DefaultUserAgent,
+		}
+	case TaskCloudflareWAF:
+		return gatsbie.CloudflareWAFSolution{
+			Cookies:   gatsbie.CloudflareWAFCookies{CfClearance: "test-cf-clearance"},
+			UserAgent: DefaultUserAgent,
+		}
+	case TaskDatadomeSlider:
+		return gatsbie.DatadomeSliderSolution{Datadome: "test-datadome-cookie", UserAgent: DefaultUserAgent}
+	case TaskCaptchaFox:
+		return gatsbie.CaptchaFoxSolution{
+			Cookie:    gatsbie.CaptchaFoxCookies{BmS: "test-bm-s", BmSc: "test-bm-sc"},
+			UserAgent: DefaultUserAgent,
+		}
+	case TaskCastle:
+		return gatsbie.CastleSolution{Token: "test-castle-token", UserAgent: DefaultUserAgent}
+	case TaskReese84:
+		return gatsbie.Reese84Solution{Reese84: "test-reese84", UserAgent: DefaultUserAgent}
+	case TaskForter:
+		return gatsbie.ForterSolution{Token: "test-forter-token", UserAgent: DefaultUserAgent}
+	case TaskFuncaptcha:
+		return gatsbie.FuncaptchaSolution{Token: "test-funcaptcha-token", UserAgent: DefaultUserAgent}
+	case TaskSBSD:
+		return gatsbie.SBSDSolution{BmS: "test-bm-s", BmSc: "test-bm-sc", UserAgent: DefaultUserAgent}
+	case TaskPerimeterXHold:
+		return gatsbie.PerimeterXHoldSolution{
+			Cookies: gatsbie.PerimeterXCookies{
+				Px3:   "test-hold-px3",
+				Pxde:  "test-hold-pxde",
+				Pxvid: "test-pxvid",
+				Pxcts: "test-pxcts",
+			},
+			UserAgent: DefaultUserAgent,
+		}
+	}
+	return nil
+}
+
+func defaultResponse(task Task) Response {
+	return Solution(DefaultSolution(task))
+}
@@ -0,0 +1,399 @@
+// Package recorder records Gatsbie and Target API traffic to cassette files
+// and replays it, so integration tests can run once against the real API
+// and then offline.
+//
+// A Recorder is an http.RoundTripper. Install it with WithHTTPClient on
+// either client:
+//
+//	rec := recorder.New(t, "testdata/turnstile.json")
+//	client := gatsbie.NewClient(apiKey, gatsbie.WithHTTPClient(rec.HTTPClient()))
+//
+// Tests replay by default. Set GATSBIE_RECORD=1 to call the real API and
+// rewrite the cassette. API keys, proxy URLs and access tokens are
+// scrubbed before anything is written to disk.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// EnvRecord is the environment variable that switches New to record mode.
+const EnvRecord = "GATSBIE_RECORD"
+
+// Redacted replaces scrubbed values in cassettes.
+const Redacted = "[REDACTED]"
+
+// Mode selects whether a Recorder talks to the network.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and fails requests
+	// that have no matching interaction.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the real API and writes the cassette
+	// when the test finishes.
+	ModeRecord
+)
+
+// Match selects which parts of a request must agree with a recorded
+// interaction for it to be replayed.
+type Match int
+
+// Request parts that can be matched. Bodies are compared after scrubbing
+// and, for JSON, after normalizing key order and whitespace.
+const (
+	MatchMethod Match = 1 << iota
+	MatchPath
+	MatchQuery
+	MatchBody
+
+	MatchAll = MatchMethod | MatchPath | MatchQuery | MatchBody
+)
+
+// defaultSecretFields are JSON fields and query parameters whose values are
+// scrubbed.
+var defaultSecretFields = []string{"proxy", "access_token", "api_key", "apiKey"}
+
+// defaultSecretHeaders are headers whose values are scrubbed.
+var defaultSecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithMode overrides the mode chosen from GATSBIE_RECORD.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithMatch sets which request parts are matched on replay. The default is
+// MatchAll.
+func WithMatch(match Match) Option {
+	return func(r *Recorder) {
+		r.match = match
+	}
+}
+
+// WithTransport sets the transport used in record mode. The default is
+// http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// WithSecretFields adds JSON fields and query parameters to scrub, in
+// addition to proxy, access_token and api_key.
+func WithSecretFields(fields ...string) Option {
+	return func(r *Recorder) {
+		for _, f := range fields {
+			r.secretFields[f] = true
+		}
+	}
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed form of a request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed form of a response.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder records or replays HTTP interactions.
+type Recorder struct {
+	t             testing.TB
+	path          string
+	mode          Mode
+	match         Match
+	transport     http.RoundTripper
+	secretFields  map[string]bool
+	secretHeaders map[string]bool
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New creates a Recorder for the cassette at path. In replay mode the
+// cassette is loaded immediately and the test fails if it cannot be read.
+// In record mode the cassette is written when the test finishes.
+func New(t testing.TB, path string, opts ...Option) *Recorder {
+	t.Helper()
+	r := &Recorder{
+		t:             t,
+		path:          path,
+		mode:          ModeReplay,
+		match:         MatchAll,
+		transport:     http.DefaultTransport,
+		secretFields:  map[string]bool{},
+		secretHeaders: map[string]bool{},
+	}
+	if v := os.Getenv(EnvRecord); v != "" && v != "0" && v != "false" {
+		r.mode = ModeRecord
+	}
+	for _, f := range defaultSecretFields {
+		r.secretFields[f] = true
+	}
+	for _, h := range defaultSecretHeaders {
+		r.secretHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	switch r.mode {
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("recorder: %v (set %s=1 to record it)", err, EnvRecord)
+		}
+		var c cassette
+		if err := json.Unmarshal(data, &c); err != nil {
+			t.Fatalf("recorder: %s: %v", path, err)
+		}
+		r.interactions = c.Interactions
+		r.used = make([]bool, len(c.Interactions))
+	case ModeRecord:
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("recorder: %v", err)
+			}
+		})
+	}
+	return r
+}
+
+// Mode returns the mode the recorder is running in.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// HTTPClient returns an HTTP client that uses the recorder as its
+// transport.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("recorder: read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	recorded := r.scrubRequest(req, body)
+
+	if r.mode == ModeRecord {
+		return r.record(req, recorded)
+	}
+	return r.replay(req, recorded)
+}
+
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("recorder: read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: r.scrubHeader(resp.Header),
+			Body:   r.scrubBody(body),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.interactions {
+		if r.used[i] || !r.matches(in.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+		header := in.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	err := fmt.Errorf("recorder: no unused interaction in %s matches %s %s (body %s)",
+		r.path, recorded.Method, recorded.URL, recorded.Body)
+	r.t.Error(err)
+	return nil, err
+}
+
+func (r *Recorder) matches(a, b RecordedRequest) bool {
+	if r.match&MatchMethod != 0 && a.Method != b.Method {
+		return false
+	}
+	ua, err1 := url.Parse(a.URL)
+	ub, err2 := url.Parse(b.URL)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if r.match&MatchPath != 0 && ua.Path != ub.Path {
+		return false
+	}
+	if r.match&MatchQuery != 0 && ua.Query().Encode() != ub.Query().Encode() {
+		return false
+	}
+	if r.match&MatchBody != 0 && a.Body != b.Body {
+		return false
+	}
+	return true
+}
+
+// save writes the recorded interactions to the cassette file.
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	c := cassette{Version: 1, Interactions: r.interactions}
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// scrubRequest returns the form of req that is stored and matched. The
+// host is dropped so that cassettes replay against any base URL.
+func (r *Recorder) scrubRequest(req *http.Request, body []byte) RecordedRequest {
+	u := *req.URL
+	u.Scheme, u.Host, u.User = "", "", nil
+	q := u.Query()
+	for key := range q {
+		if r.secretFields[key] {
+			q.Set(key, Redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    u.String(),
+		Header: r.scrubHeader(req.Header),
+		Body:   r.scrubBody(body),
+	}
+}
+
+// scrubHeader copies h with secret headers redacted and volatile headers
+// removed.
+func (r *Recorder) scrubHeader(h http.Header) http.Header {
+	out := http.Header{}
+	for key, values := range h {
+		switch {
+		case key == "Date" || key == "Content-Length" || key == "Idempotency-Key":
+			// Volatile, or no longer accurate once the body is scrubbed.
+			continue
+		case r.secretHeaders[key]:
+			out[key] = []string{Redacted}
+		default:
+			out[key] = append([]string(nil), values...)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// scrubBody redacts secret fields in a JSON body and normalizes it. Other
+// bodies are returned unchanged.
+func (r *Recorder) scrubBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	v = r.scrubValue(v)
+	out, err := json.Marshal(v) // map keys are sorted by encoding/json
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+func (r *Recorder) scrubValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k := range v {
+			if s, ok := v[k].(string); ok && r.secretFields[k] && s != "" {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = r.scrubValue(v[k])
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = r.scrubValue(v[i])
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,436 @@
+// Package gatsbietest provides an in-process fake of the Gatsbie API for
+// testing code that uses the gatsbie package without spending credits.
+//
+// A Server implements /health and every /v1/solve/* endpoint. Each task
+// answers with a canned solution by default; tests can script solutions,
+// latency, API errors and malformed bodies per task, and inspect the
+// payloads the client sent:
+//
+//	srv := gatsbietest.NewServer(t)
+//	srv.Handle(gatsbietest.TaskTurnstile,
+//		gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "try again"),
+//		gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "tok"}),
+//	)
+//
+//	client := srv.Client()
+//	resp, err := client.SolveTurnstile(ctx, &gatsbie.TurnstileRequest{...})
+//
+//	srv.ExpectField(gatsbietest.TaskTurnstile, "task_type", "turnstile")
+package gatsbietest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// TestAPIKey is the API key accepted by a Server unless RequireAPIKey
+// configures another one.
+const TestAPIKey = "gats_test_key"
+
+// Task identifies an API endpoint by the last element of its path.
+type Task string
+
+// Tasks served by Server.
+const (
+	TaskHealth              Task = "health"
+	TaskDatadome            Task = "datadome-device-check"
+	TaskRecaptcha           Task = "recaptcha"
+	TaskRecaptchaEnterprise Task = "recaptcha-enterprise"
+	TaskAkamai              Task = "akamai"
+	TaskVercel              Task = "vercel"
+	TaskShape               Task = "shape"
+	TaskShapeV2             Task = "shape-v2"
+	TaskTurnstile           Task = "turnstile"
+	TaskPerimeterX          Task = "perimeterx-invisible"
+	TaskCloudflareWAF       Task = "cloudflare-waf"
+	TaskDatadomeSlider      Task = "datadome-slider"
+	TaskCaptchaFox          Task = "captchafox"
+	TaskCastle              Task = "castle"
+	TaskReese84             Task = "reese84"
+	TaskForter              Task = "forter"
+	TaskFuncaptcha          Task = "funcaptcha"
+	TaskSBSD                Task = "sbsd"
+	TaskPerimeterXHold      Task = "perimeterx-hold"
+)
+
+// Tasks lists every solve task served by Server, in the order the gatsbie
+// package declares them.
+var Tasks = []Task{
+	TaskDatadome, TaskRecaptcha, TaskRecaptchaEnterprise, TaskAkamai,
+	TaskVercel, TaskShape, TaskShapeV2, TaskTurnstile, TaskPerimeterX,
+	TaskCloudflareWAF, TaskDatadomeSlider, TaskCaptchaFox, TaskCastle,
+	TaskReese84, TaskForter, TaskFuncaptcha, TaskSBSD, TaskPerimeterXHold,
+}
+
+// Path returns the URL path of the task's endpoint.
+func (t Task) Path() string {
+	if t == TaskHealth {
+		return "/health"
+	}
+	return "/v1/solve/" + string(t)
+}
+
+// Response is a scripted reply to a request. Build one with Solution,
+// Error or Malformed and adjust it with its With methods.
+type Response struct {
+	// Status is the HTTP status code. Zero means 200 for solutions and a
+	// code-dependent default for errors.
+	Status int
+	// Solution is encoded as the "solution" field of a successful solve
+	// response, or as the whole body for TaskHealth.
+	Solution any
+	// Cost and SolveTime are reported in successful solve responses.
+	Cost      float64
+	SolveTime float64
+	// Err, if set, is returned in the API's error envelope.
+	Err *gatsbie.APIError
+	// Body, if set, is written verbatim instead of a JSON envelope.
+	Body        string
+	ContentType string
+	// Delay is waited before responding. The wait ends early if the client
+	// gives up on the request.
+	Delay time.Duration
+}
+
+// Solution returns a successful response carrying solution.
+func Solution(solution any) Response {
+	return Response{Solution: solution, Cost: 0.001, SolveTime: 1500}
+}
+
+// Error returns an API error response with the given code, such as
+// gatsbie.ErrCodeSolveFailed. The HTTP status follows the code: 401 for
+// AUTH_FAILED, 402 for INSUFFICIENT_CREDITS, 400 for INVALID_REQUEST, 422
+// for SOLVE_FAILED, 502 for UPSTREAM_ERROR and 500 otherwise.
+func Error(code, message string) Response {
+	return Response{Err: &gatsbie.APIError{Code: code, Message: message}}
+}
+
+// Malformed returns a response whose body is written verbatim, for
+// exercising decoding failures such as truncated JSON or HTML error pages.
+func Malformed(status int, body string) Response {
+	return Response{Status: status, Body: body, ContentType: "text/html; charset=utf-8"}
+}
+
+// WithDelay returns a copy of r that is sent after d.
+func (r Response) WithDelay(d time.Duration) Response {
+	r.Delay = d
+	return r
+}
+
+// WithStatus returns a copy of r with the given HTTP status code.
+func (r Response) WithStatus(status int) Response {
+	r.Status = status
+	return r
+}
+
+// WithCost returns a copy of r reporting the given cost.
+func (r Response) WithCost(cost float64) Response {
+	r.Cost = cost
+	return r
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Task   Task
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the request body into a generic value.
+func (r Request) JSON() map[string]any {
+	var m map[string]any
+	_ = json.Unmarshal(r.Body, &m)
+	return m
+}
+
+// Field returns the value at a dotted path in the JSON body, such as
+// "target_url" or "metadata.proxy", and whether it was present.
+func (r Request) Field(path string) (any, bool) {
+	var v any = r.JSON()
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Server is a fake Gatsbie API server.
+type Server struct {
+	// URL is the base URL of the server, for gatsbie.WithBaseURL.
+	URL string
+
+	t      testing.TB
+	server *httptest.Server
+
+	mu       sync.Mutex
+	apiKey   string
+	scripts  map[Task][]Response
+	requests []Request
+	taskSeq  int
+}
+
+// NewServer starts a Server that is closed when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		t:       t,
+		apiKey:  TestAPIKey,
+		scripts: map[Task][]Response{},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close shuts the server down. It is called automatically at the end of
+// the test.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a gatsbie client pointed at the server and authenticated
+// with the server's API key. Extra options are applied after the defaults.
+func (s *Server) Client(opts ...gatsbie.Option) *gatsbie.Client {
+	s.mu.Lock()
+	key := s.apiKey
+	s.mu.Unlock()
+
+	c := gatsbie.NewClient(key, append([]gatsbie.Option{gatsbie.WithBaseURL(s.URL)}, opts...)...)
+	s.t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// RequireAPIKey makes the server reject requests that do not carry key
+// with an AUTH_FAILED error. An empty key accepts any key.
+func (s *Server) RequireAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// Handle scripts the responses for task. Responses are served in order and
+// the last one repeats for every later request. Calling Handle again
+// replaces the script.
+func (s *Server) Handle(task Task, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[task] = append([]Response(nil), responses...)
+}
+
+// Requests returns the requests received for task, oldest first. With no
+// task it returns every request.
+func (s *Server) Requests(task ...Task) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Request
+	for _, r := range s.requests {
+		if len(task) == 0 || r.Task == task[0] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// LastRequest returns the most recent request for task. It fails the test
+// if none was received.
+func (s *Server) LastRequest(task Task) Request {
+	s.t.Helper()
+	reqs := s.Requests(task)
+	if len(reqs) == 0 {
+		s.t.Fatalf("gatsbietest: no request received for %s", task)
+		return Request{}
+	}
+	return reqs[len(reqs)-1]
+}
+
+// ExpectField fails the test unless the last request for task has want at
+// the dotted path in its JSON body. Numbers compare as float64.
+func (s *Server) ExpectField(task Task, path string, want any) {
+	s.t.Helper()
+	req := s.LastRequest(task)
+	got, ok := req.Field(path)
+	if !ok {
+		s.t.Errorf("gatsbietest: %s request has no field %q; body: %s", task, path, req.Body)
+		return
+	}
+	if !jsonEqual(got, want) {
+		s.t.Errorf("gatsbietest: %s request field %q = %#v, want %#v", task, path, got, want)
+	}
+}
+
+// ExpectNoField fails the test if the last request for task has a value at
+// the dotted path in its JSON body.
+func (s *Server) ExpectNoField(task Task, path string) {
+	s.t.Helper()
+	req := s.LastRequest(task)
+	if got, ok := req.Field(path); ok {
+		s.t.Errorf("gatsbietest: %s request has unexpected field %q = %#v", task, path, got)
+	}
+}
+
+func jsonEqual(got, want any) bool {
+	a, err1 := json.Marshal(got)
+	b, err2 := json.Marshal(want)
+	return err1 == nil && err2 == nil && bytes.Equal(a, b)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	task, ok := taskForPath(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusNotFound, &gatsbie.APIError{Code: gatsbie.ErrCodeInvalidRequest, Message: "unknown endpoint " + r.URL.Path})
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Task:   task,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	resp := s.next(task)
+	apiKey := s.apiKey
+	s.taskSeq++
+	taskID := fmt.Sprintf("task_%06d", s.taskSeq)
+	s.mu.Unlock()
+
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if task != TaskHealth {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, &gatsbie.APIError{Code: gatsbie.ErrCodeInvalidRequest, Message: "method not allowed"})
+			return
+		}
+		if apiKey != "" && r.Header.Get("Authorization") != "Bearer "+apiKey {
+			writeError(w, http.StatusUnauthorized, &gatsbie.APIError{Code: gatsbie.ErrCodeAuthFailed, Message: "invalid API key"})
+			return
+		}
+		if !json.Valid(body) {
+			writeError(w, http.StatusBadRequest, &gatsbie.APIError{Code: gatsbie.ErrCodeInvalidRequest, Message: "request body is not valid JSON"})
+			return
+		}
+	}
+
+	switch {
+	case resp.Body != "":
+		ct := resp.ContentType
+		if ct == "" {
+			ct = "application/json"
+		}
+		w.Header().Set("Content-Type", ct)
+		w.WriteHeader(statusOr(resp.Status, http.StatusOK))
+		io.WriteString(w, resp.Body)
+	case resp.Err != nil:
+		apiErr := *resp.Err
+		if apiErr.Timestamp == 0 {
+			apiErr.Timestamp = time.Now().UnixMilli()
+		}
+		writeJSON(w, statusOr(resp.Status, statusForCode(apiErr.Code)), map[string]any{
+			"success": false,
+			"taskId":  taskID,
+			"error":   &apiErr,
+		})
+	case task == TaskHealth:
+		writeJSON(w, statusOr(resp.Status, http.StatusOK), resp.Solution)
+	default:
+		writeJSON(w, statusOr(resp.Status, http.StatusOK), map[string]any{
+			"success":   true,
+			"taskId":    taskID,
+			"service":   string(task),
+			"solution":  resp.Solution,
+			"cost":      resp.Cost,
+			"solveTime": resp.SolveTime,
+		})
+	}
+}
+
+// next returns the scripted response for task, or the default. s.mu must
+// be held.
+func (s *Server) next(task Task) Response {
+	script := s.scripts[task]
+	if len(script) == 0 {
+		return defaultResponse(task)
+	}
+	resp := script[0]
+	if len(script) > 1 {
+		s.scripts[task] = script[1:]
+	}
+	return resp
+}
+
+func taskForPath(path string) (Task, bool) {
+	if path == "/health" {
+		return TaskHealth, true
+	}
+	name, ok := strings.CutPrefix(path, "/v1/solve/")
+	if !ok {
+		return "", false
+	}
+	for _, t := range Tasks {
+		if string(t) == name {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func statusOr(status, def int) int {
+	if status != 0 {
+		return status
+	}
+	return def
+}
+
+func statusForCode(code string) int {
+	switch code {
+	case gatsbie.ErrCodeAuthFailed:
+		return http.StatusUnauthorized
+	case gatsbie.ErrCodeInsufficientCredits:
+		return http.StatusPaymentRequired
+	case gatsbie.ErrCodeInvalidRequest:
+		return http.StatusBadRequest
+	case gatsbie.ErrCodeSolveFailed:
+		return http.StatusUnprocessableEntity
+	case gatsbie.ErrCodeUpstreamError:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, apiErr *gatsbie.APIError) {
+	apiErr.Timestamp = time.Now().UnixMilli()
+	writeJSON(w, status, map[string]any{"success": false, "error": apiErr})
+}
@@ -0,0 +1,339 @@
+package gatsbietest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+// envelope is the body of a solve or error response.
+type envelope struct {
+	Success   bool              `json:"success"`
+	TaskID    string            `json:"taskId"`
+	Service   string            `json:"service"`
+	Solution  json.RawMessage   `json:"solution"`
+	Cost      float64           `json:"cost"`
+	SolveTime float64           `json:"solveTime"`
+	Error     *gatsbie.APIError `json:"error"`
+}
+
+// call sends a request to the server and returns the response with its
+// body read.
+func call(t *testing.T, srv *gatsbietest.Server, method, path, apiKey, body string) (*http.Response, []byte) {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+func decode(t *testing.T, data []byte) envelope {
+	t.Helper()
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatalf("body is not JSON: %s", data)
+	}
+	return env
+}
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		body   string
+		status int
+		code   string
+	}{
+		{"health without a key", http.MethodGet, "/health", "", "", http.StatusOK, ""},
+		{"solve", http.MethodPost, "/v1/solve/turnstile", gatsbietest.TestAPIKey, "{}", http.StatusOK, ""},
+		{"unknown task", http.MethodPost, "/v1/solve/unknown", gatsbietest.TestAPIKey, "{}", http.StatusNotFound, gatsbie.ErrCodeInvalidRequest},
+		{"unknown path", http.MethodGet, "/v2/health", "", "", http.StatusNotFound, gatsbie.ErrCodeInvalidRequest},
+		{"GET on a solve endpoint", http.MethodGet, "/v1/solve/turnstile", gatsbietest.TestAPIKey, "", http.StatusMethodNotAllowed, gatsbie.ErrCodeInvalidRequest},
+		{"missing key", http.MethodPost, "/v1/solve/turnstile", "", "{}", http.StatusUnauthorized, gatsbie.ErrCodeAuthFailed},
+		{"wrong key", http.MethodPost, "/v1/solve/turnstile", "gats_other", "{}", http.StatusUnauthorized, gatsbie.ErrCodeAuthFailed},
+		{"body not JSON", http.MethodPost, "/v1/solve/turnstile", gatsbietest.TestAPIKey, "{", http.StatusBadRequest, gatsbie.ErrCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			resp, data := call(t, srv, tt.method, tt.path, tt.key, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, data)
+			}
+			env := decode(t, data)
+			if tt.code == "" {
+				return
+			}
+			if env.Success || env.Error == nil || env.Error.Code != tt.code {
+				t.Errorf("body = %s, want error %s", data, tt.code)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.RequireAPIKey("gats_custom")
+	if resp, data := call(t, srv, http.MethodPost, "/v1/solve/turnstile", gatsbietest.TestAPIKey, "{}"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("TestAPIKey: status = %d, want 401: %s", resp.StatusCode, data)
+	}
+	if resp, data := call(t, srv, http.MethodPost, "/v1/solve/turnstile", "gats_custom", "{}"); resp.StatusCode != http.StatusOK {
+		t.Errorf("custom key: status = %d, want 200: %s", resp.StatusCode, data)
+	}
+	// Client uses the required key.
+	if _, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Errorf("Client(): %v", err)
+	}
+
+	srv.RequireAPIKey("")
+	if resp, data := call(t, srv, http.MethodPost, "/v1/solve/turnstile", "gats_anything", "{}"); resp.StatusCode != http.StatusOK {
+		t.Errorf("empty key: status = %d, want 200: %s", resp.StatusCode, data)
+	}
+}
+
+func TestDefaultResponses(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	for _, task := range gatsbietest.Tasks {
+		t.Run(string(task), func(t *testing.T) {
+			resp, data := call(t, srv, http.MethodPost, task.Path(), gatsbietest.TestAPIKey, "{}")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, data)
+			}
+			env := decode(t, data)
+			if !env.Success || env.TaskID == "" || env.Service != string(task) || env.Cost <= 0 {
+				t.Errorf("envelope = %s", data)
+			}
+			want, _ := json.Marshal(gatsbietest.DefaultSolution(task))
+			if string(env.Solution) != string(want) {
+				t.Errorf("solution = %s, want %s", env.Solution, want)
+			}
+		})
+	}
+
+	resp, data := call(t, srv, http.MethodGet, "/health", "", "")
+	var health gatsbie.HealthResponse
+	if err := json.Unmarshal(data, &health); err != nil || resp.StatusCode != http.StatusOK || health.Status != "ok" {
+		t.Errorf("health = %d %s", resp.StatusCode, data)
+	}
+
+	// The client decodes a default solution.
+	client := srv.Client()
+	if _, err := client.SolveAkamai(context.Background(), &gatsbie.AkamaiRequest{}); err != nil {
+		t.Errorf("SolveAkamai: %v", err)
+	}
+}
+
+func TestTaskIDsAreUnique(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		_, data := call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+		id := decode(t, data).TaskID
+		if seen[id] {
+			t.Errorf("task ID %q repeated", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	tests := []struct {
+		code   string
+		status int
+	}{
+		{gatsbie.ErrCodeAuthFailed, http.StatusUnauthorized},
+		{gatsbie.ErrCodeInsufficientCredits, http.StatusPaymentRequired},
+		{gatsbie.ErrCodeInvalidRequest, http.StatusBadRequest},
+		{gatsbie.ErrCodeSolveFailed, http.StatusUnprocessableEntity},
+		{gatsbie.ErrCodeUpstreamError, http.StatusBadGateway},
+		{gatsbie.ErrCodeInternalError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Error(tt.code, "scripted"))
+			resp, data := call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			env := decode(t, data)
+			if env.Success || env.TaskID == "" || env.Error == nil || env.Error.Code != tt.code || env.Error.Message != "scripted" || env.Error.Timestamp == 0 {
+				t.Errorf("body = %s", data)
+			}
+		})
+	}
+}
+
+func TestScriptedResponses(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile,
+		gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "first").WithStatus(http.StatusTeapot),
+		gatsbietest.Malformed(http.StatusBadGateway, "<html>bad gateway</html>"),
+		gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "scripted"}).WithCost(0.25),
+	)
+
+	resp, data := call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+	if env := decode(t, data); resp.StatusCode != http.StatusTeapot || env.Error == nil || env.Error.Message != "first" {
+		t.Errorf("first response = %d %s", resp.StatusCode, data)
+	}
+
+	resp, data = call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+	if resp.StatusCode != http.StatusBadGateway || string(data) != "<html>bad gateway</html>" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("malformed response = %d %q %s", resp.StatusCode, resp.Header.Get("Content-Type"), data)
+	}
+
+	// The last response repeats.
+	for i := 0; i < 2; i++ {
+		_, data = call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+		if env := decode(t, data); env.Cost != 0.25 || !strings.Contains(string(env.Solution), `"token":"scripted"`) {
+			t.Errorf("response %d = %s", i+3, data)
+		}
+	}
+
+	// Other tasks keep their defaults, and Handle replaces a script.
+	_, data = call(t, srv, http.MethodPost, gatsbietest.TaskCastle.Path(), gatsbietest.TestAPIKey, "{}")
+	if env := decode(t, data); !env.Success {
+		t.Errorf("castle = %s", data)
+	}
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "replaced"}))
+	_, data = call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+	if env := decode(t, data); !strings.Contains(string(env.Solution), `"token":"replaced"`) {
+		t.Errorf("after Handle = %s", data)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)).WithDelay(50*time.Millisecond))
+
+	start := time.Now()
+	call(t, srv, http.MethodPost, gatsbietest.TaskTurnstile.Path(), gatsbietest.TestAPIKey, "{}")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("response came after %v, want at least 50ms", elapsed)
+	}
+
+	// The wait ends when the client gives up.
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbietest.DefaultSolution(gatsbietest.TaskTurnstile)).WithDelay(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := srv.Client().SolveTurnstile(ctx, &gatsbie.TurnstileRequest{}); err == nil {
+		t.Error("solve with an hour's delay succeeded")
+	}
+}
+
+func TestRequestCapture(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	client := srv.Client()
+	ctx := context.Background()
+	if _, err := client.Health(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://one.example/", "https://two.example/"} {
+		if _, err := client.SolveTurnstile(ctx, &gatsbie.TurnstileRequest{TargetURL: url, SiteKey: "0xKEY", Proxy: "http://proxy:8080"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.SolveShapeV2(ctx, &gatsbie.ShapeV2Request{Request: &gatsbie.ShapeV2ProtectedRequest{Method: "POST"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(srv.Requests()); n != 4 {
+		t.Errorf("Requests() = %d, want 4", n)
+	}
+	turnstile := srv.Requests(gatsbietest.TaskTurnstile)
+	if len(turnstile) != 2 {
+		t.Fatalf("Requests(turnstile) = %d, want 2", len(turnstile))
+	}
+	first := turnstile[0]
+	if first.Task != gatsbietest.TaskTurnstile || first.Method != http.MethodPost || first.Path != "/v1/solve/turnstile" {
+		t.Errorf("request = %+v", first)
+	}
+	if first.Header.Get("Authorization") != "Bearer "+gatsbietest.TestAPIKey {
+		t.Errorf("Authorization = %q", first.Header.Get("Authorization"))
+	}
+	if first.JSON()["target_url"] != "https://one.example/" {
+		t.Errorf("JSON() = %v", first.JSON())
+	}
+	if srv.LastRequest(gatsbietest.TaskTurnstile).JSON()["target_url"] != "https://two.example/" {
+		t.Error("LastRequest is not the most recent")
+	}
+	if srv.LastRequest(gatsbietest.TaskHealth).Method != http.MethodGet {
+		t.Error("health request not captured")
+	}
+
+	srv.ExpectField(gatsbietest.TaskTurnstile, "site_key", "0xKEY")
+	srv.ExpectField(gatsbietest.TaskShapeV2, "metadata.request.method", "POST")
+	srv.ExpectNoField(gatsbietest.TaskShapeV2, "metadata.request.url")
+	if v, ok := srv.LastRequest(gatsbietest.TaskShapeV2).Field("metadata.request.headers"); ok {
+		t.Errorf("Field(metadata.request.headers) = %v, want absent", v)
+	}
+}
+
+// fakeT records the failures the server reports instead of failing the
+// test.
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...any) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestExpectationFailures(t *testing.T) {
+	ft := &fakeT{TB: t}
+	srv := gatsbietest.NewServer(ft)
+
+	srv.LastRequest(gatsbietest.TaskTurnstile)
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], "no request received for turnstile") {
+		t.Fatalf("failures = %q, want no request received", ft.failures)
+	}
+
+	if _, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{SiteKey: "0xKEY"}); err != nil {
+		t.Fatal(err)
+	}
+	ft.failures = nil
+	srv.ExpectField(gatsbietest.TaskTurnstile, "site_key", "0xOTHER")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "missing", "x")
+	srv.ExpectNoField(gatsbietest.TaskTurnstile, "site_key")
+	if len(ft.failures) != 3 {
+		t.Fatalf("failures = %q, want 3", ft.failures)
+	}
+	for i, want := range []string{`field "site_key" = "0xKEY", want "0xOTHER"`, `has no field "missing"`, `unexpected field "site_key"`} {
+		if !strings.Contains(ft.failures[i], want) {
+			t.Errorf("failure %d = %q, want it to contain %q", i, ft.failures[i], want)
+		}
+	}
+}
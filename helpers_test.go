@@ -0,0 +1,17 @@
+package gatsbie_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFixture returns the contents of the file at parts under testdata.
+func readFixture(t *testing.T, parts ...string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(append([]string{"testdata"}, parts...)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
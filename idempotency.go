@@ -0,0 +1,75 @@
+package gatsbie
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+// idempotencyHeader is the request header carrying the idempotency key.
+const idempotencyHeader = "Idempotency-Key"
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context that makes the next solve request
+// use key instead of a generated one. Use it to make a solve safe to
+// repeat across process restarts, for example with a job ID.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key set with
+// WithIdempotencyKey, if any.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+// IdempotencyKeyFromError returns the idempotency key of the solve request
+// that produced err. It is empty if err did not come from a solve request.
+func IdempotencyKeyFromError(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.IdempotencyKey
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.IdempotencyKey
+	}
+	return ""
+}
+
+// newIdempotencyKey generates a random idempotency key.
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; an empty key
+		// just means the request is sent without one.
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// idempotent is implemented by responses that record the idempotency key
+// of the request that produced them.
+type idempotent interface {
+	setIdempotencyKey(key string)
+}
+
+func (r *SolveResponse[T]) setIdempotencyKey(key string) {
+	r.IdempotencyKey = key
+}
+
+// attemptContext returns the context for the nth solve of a multi-solve
+// helper. A key set with WithIdempotencyKey is suffixed with the attempt
+// number so that each attempt is a fresh solve but the sequence stays
+// repeatable; the first attempt uses the key unchanged.
+func attemptContext(ctx context.Context, n int) context.Context {
+	key, ok := IdempotencyKeyFromContext(ctx)
+	if !ok || n <= 1 {
+		return ctx
+	}
+	return WithIdempotencyKey(ctx, key+"-"+strconv.Itoa(n))
+}
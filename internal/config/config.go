@@ -0,0 +1,543 @@
+// Package config loads client settings from environment variables and
+// from JSON, YAML or TOML profile files. It is shared by the gatsbie and
+// target packages, which re-export its types.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables read by FromEnv.
+const (
+	EnvConfigFile     = "GATSBIE_CONFIG"
+	EnvProfile        = "GATSBIE_PROFILE"
+	EnvAPIKey         = "GATSBIE_API_KEY"
+	EnvAPIKeys        = "GATSBIE_API_KEYS"
+	EnvBaseURL        = "GATSBIE_BASE_URL"
+	EnvBaseURLs       = "GATSBIE_BASE_URLS"
+	EnvTargetBaseURL  = "GATSBIE_TARGET_BASE_URL"
+	EnvTargetBaseURLs = "GATSBIE_TARGET_BASE_URLS"
+	EnvTimeout        = "GATSBIE_TIMEOUT"
+	EnvMaxRetries     = "GATSBIE_MAX_RETRIES"
+	EnvRetryBackoff   = "GATSBIE_RETRY_BACKOFF"
+	EnvRateLimit      = "GATSBIE_RATE_LIMIT"
+	EnvRateBurst      = "GATSBIE_RATE_BURST"
+	EnvProxy          = "GATSBIE_PROXY"
+)
+
+// DefaultProfile is the profile used when neither the caller, the
+// environment nor the file selects one.
+const DefaultProfile = "default"
+
+// Config holds client settings. Zero values mean "use the client default".
+type Config struct {
+	APIKeys        []string
+	BaseURLs       []string
+	TargetBaseURLs []string
+	Timeout        time.Duration
+	MaxRetries     int
+	RetryBackoff   time.Duration
+	RateLimit      float64 // requests per second
+	RateBurst      int
+	Proxy          string
+}
+
+// Error is a configuration error. Key names the offending setting, as a
+// dotted path for files (profiles.prod.timeout) or the variable name for
+// the environment (GATSBIE_TIMEOUT).
+type Error struct {
+	Source string // file path or "environment"
+	Key    string
+	Msg    string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("config: %s: %s: %s", e.Source, e.Key, e.Msg)
+	}
+	return fmt.Sprintf("config: %s: %s", e.Key, e.Msg)
+}
+
+// Load reads the named profile from a JSON, YAML or TOML file, chosen by
+// extension (.json, .yaml/.yml, .toml). An empty profile selects the
+// GATSBIE_PROFILE environment variable, then the file's default_profile,
+// then "default".
+//
+// A file may define settings at the top level, under profiles.<name>, or
+// both; profile settings override top-level ones.
+func Load(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		doc, err = parseYAML(data)
+	case ".toml":
+		doc, err = parseTOML(data)
+	default:
+		return nil, &Error{Source: path, Key: "(file)", Msg: fmt.Sprintf("unsupported extension %q, want .json, .yaml, .yml or .toml", ext)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	cfg, err := decodeFile(doc, profile)
+	if err != nil {
+		if ce, ok := err.(*Error); ok {
+			ce.Source = path
+		}
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FromEnv builds a Config from the environment. If GATSBIE_CONFIG names a
+// file it is loaded first and individual variables override its values.
+func FromEnv() (*Config, error) {
+	return FromEnvFile("", "")
+}
+
+// FromEnvFile is like FromEnv, but path and profile, if not empty, are
+// used in place of GATSBIE_CONFIG and GATSBIE_PROFILE. The environment is
+// not modified.
+func FromEnvFile(path, profile string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	cfg := &Config{}
+	if path != "" {
+		loaded, err := Load(path, profile)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	envErr := func(key, msg string) error {
+		return &Error{Source: "environment", Key: key, Msg: msg}
+	}
+
+	// Errors name the variable a list was read from.
+	keys := envKeys
+	if v := os.Getenv(EnvAPIKeys); v != "" {
+		cfg.APIKeys = splitList(v)
+		keys.apiKeys = EnvAPIKeys
+	} else if v := os.Getenv(EnvAPIKey); v != "" {
+		cfg.APIKeys = []string{v}
+	}
+	if v := os.Getenv(EnvBaseURLs); v != "" {
+		cfg.BaseURLs = splitList(v)
+		keys.baseURLs = EnvBaseURLs
+	} else if v := os.Getenv(EnvBaseURL); v != "" {
+		cfg.BaseURLs = []string{v}
+	}
+	if v := os.Getenv(EnvTargetBaseURLs); v != "" {
+		cfg.TargetBaseURLs = splitList(v)
+		keys.targetBaseURLs = EnvTargetBaseURLs
+	} else if v := os.Getenv(EnvTargetBaseURL); v != "" {
+		cfg.TargetBaseURLs = []string{v}
+	}
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := parseDuration(v)
+		if err != nil {
+			return nil, envErr(EnvTimeout, err.Error())
+		}
+		cfg.Timeout = d
+	}
+	if v := os.Getenv(EnvMaxRetries); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, envErr(EnvMaxRetries, fmt.Sprintf("invalid integer %q", v))
+		}
+		cfg.MaxRetries = n
+	}
+	if v := os.Getenv(EnvRetryBackoff); v != "" {
+		d, err := parseDuration(v)
+		if err != nil {
+			return nil, envErr(EnvRetryBackoff, err.Error())
+		}
+		cfg.RetryBackoff = d
+	}
+	if v := os.Getenv(EnvRateLimit); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, envErr(EnvRateLimit, fmt.Sprintf("invalid number %q", v))
+		}
+		cfg.RateLimit = f
+	}
+	if v := os.Getenv(EnvRateBurst); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, envErr(EnvRateBurst, fmt.Sprintf("invalid integer %q", v))
+		}
+		cfg.RateBurst = n
+	}
+	if v := os.Getenv(EnvProxy); v != "" {
+		cfg.Proxy = v
+	}
+
+	if err := cfg.validate(keys); err != nil {
+		err.Source = "environment"
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// keyNames maps each setting to the name used in error messages.
+type keyNames struct {
+	apiKeys, baseURLs, targetBaseURLs, timeout            string
+	maxRetries, retryBackoff, rateLimit, rateBurst, proxy string
+}
+
+var envKeys = keyNames{
+	apiKeys:        EnvAPIKey,
+	baseURLs:       EnvBaseURL,
+	targetBaseURLs: EnvTargetBaseURL,
+	timeout:        EnvTimeout,
+	maxRetries:     EnvMaxRetries,
+	retryBackoff:   EnvRetryBackoff,
+	rateLimit:      EnvRateLimit,
+	rateBurst:      EnvRateBurst,
+	proxy:          EnvProxy,
+}
+
+func fileKeys(prefix string) keyNames {
+	return keyNames{
+		apiKeys:        prefix + "api_keys",
+		baseURLs:       prefix + "base_urls",
+		targetBaseURLs: prefix + "target_base_urls",
+		timeout:        prefix + "timeout",
+		maxRetries:     prefix + "retry.max_retries",
+		retryBackoff:   prefix + "retry.backoff",
+		rateLimit:      prefix + "rate_limit.requests_per_second",
+		rateBurst:      prefix + "rate_limit.burst",
+		proxy:          prefix + "proxy",
+	}
+}
+
+// validate checks value ranges and URL syntax.
+func (c *Config) validate(keys keyNames) *Error {
+	for _, k := range c.APIKeys {
+		if strings.TrimSpace(k) == "" {
+			return &Error{Key: keys.apiKeys, Msg: "empty API key"}
+		}
+	}
+	for _, u := range c.BaseURLs {
+		if err := checkURL(u); err != nil {
+			return &Error{Key: keys.baseURLs, Msg: err.Error()}
+		}
+	}
+	for _, u := range c.TargetBaseURLs {
+		if err := checkURL(u); err != nil {
+			return &Error{Key: keys.targetBaseURLs, Msg: err.Error()}
+		}
+	}
+	if c.Timeout < 0 {
+		return &Error{Key: keys.timeout, Msg: "must not be negative"}
+	}
+	if c.MaxRetries < 0 {
+		return &Error{Key: keys.maxRetries, Msg: "must not be negative"}
+	}
+	if c.RetryBackoff < 0 {
+		return &Error{Key: keys.retryBackoff, Msg: "must not be negative"}
+	}
+	if c.RateLimit < 0 {
+		return &Error{Key: keys.rateLimit, Msg: "must not be negative"}
+	}
+	if c.RateBurst < 0 {
+		return &Error{Key: keys.rateBurst, Msg: "must not be negative"}
+	}
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			return &Error{Key: keys.proxy, Msg: fmt.Sprintf("invalid proxy URL %q", redact(c.Proxy))}
+		}
+	}
+	return nil
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q, want http(s)://host", raw)
+	}
+	return nil
+}
+
+// redact hides credentials in a proxy URL so they do not end up in logs.
+func redact(raw string) string {
+	if i := strings.LastIndex(raw, "@"); i >= 0 {
+		if j := strings.Index(raw, "://"); j >= 0 && j < i {
+			return raw[:j+3] + "***@" + raw[i+1:]
+		}
+		return "***@" + raw[i+1:]
+	}
+	return raw
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseDuration accepts Go duration strings ("90s", "2m") and plain
+// numbers, which are taken as seconds.
+func parseDuration(v string) (time.Duration, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, nil
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("invalid duration %q", v)
+}
+
+// decodeFile selects and decodes a profile from a parsed document.
+func decodeFile(doc map[string]any, profile string) (*Config, error) {
+	base := map[string]any{}
+	var profiles map[string]any
+	defaultProfile := ""
+
+	for key, v := range doc {
+		switch key {
+		case "profiles":
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, &Error{Key: key, Msg: "must be a table of profiles"}
+			}
+			profiles = m
+		case "default_profile":
+			s, ok := v.(string)
+			if !ok {
+				return nil, &Error{Key: key, Msg: "must be a string"}
+			}
+			defaultProfile = s
+		default:
+			base[key] = v
+		}
+	}
+
+	cfg := &Config{}
+	if err := decodeProfile(cfg, "", base); err != nil {
+		return nil, err
+	}
+	// Validate the top-level settings before a profile overrides them, so
+	// that errors name the key that holds the bad value.
+	if err := cfg.validate(fileKeys("")); err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+	explicit := profile != ""
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	if p, ok := profiles[profile]; ok {
+		m, ok := p.(map[string]any)
+		if !ok {
+			return nil, &Error{Key: "profiles." + profile, Msg: "must be a table"}
+		}
+		prefix := "profiles." + profile + "."
+		if err := decodeProfile(cfg, prefix, m); err != nil {
+			return nil, err
+		}
+		if err := cfg.validate(fileKeys(prefix)); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	if explicit || defaultProfile != "" {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, &Error{Key: "profiles." + profile, Msg: fmt.Sprintf("profile not found (have %s)", strings.Join(names, ", "))}
+	}
+	return cfg, nil
+}
+
+// exclusiveKeys are pairs of settings that set the same value and so
+// cannot both appear in one table.
+var exclusiveKeys = [][2]string{
+	{"api_key", "api_keys"},
+	{"base_url", "base_urls"},
+	{"target_base_url", "target_base_urls"},
+}
+
+// sortedKeys returns the keys of m in order, so that errors are reported
+// deterministically.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeProfile applies the settings in m to cfg. prefix is prepended to
+// key names in errors.
+func decodeProfile(cfg *Config, prefix string, m map[string]any) error {
+	for _, pair := range exclusiveKeys {
+		_, one := m[pair[0]]
+		_, many := m[pair[1]]
+		if one && many {
+			return &Error{Key: prefix + pair[0], Msg: fmt.Sprintf("cannot be set together with %s", pair[1])}
+		}
+	}
+	for _, key := range sortedKeys(m) {
+		v := m[key]
+		path := prefix + key
+		var err error
+		switch key {
+		case "api_key":
+			var s string
+			if s, err = asString(v); err == nil {
+				cfg.APIKeys = []string{s}
+			}
+		case "api_keys":
+			cfg.APIKeys, err = asStrings(v)
+		case "base_url":
+			var s string
+			if s, err = asString(v); err == nil {
+				cfg.BaseURLs = []string{s}
+			}
+		case "base_urls":
+			cfg.BaseURLs, err = asStrings(v)
+		case "target_base_url":
+			var s string
+			if s, err = asString(v); err == nil {
+				cfg.TargetBaseURLs = []string{s}
+			}
+		case "target_base_urls":
+			cfg.TargetBaseURLs, err = asStrings(v)
+		case "timeout":
+			cfg.Timeout, err = asDuration(v)
+		case "proxy":
+			cfg.Proxy, err = asString(v)
+		case "retry":
+			err = decodeTable(path, v, map[string]func(any) error{
+				"max_retries": func(v any) (err error) { cfg.MaxRetries, err = asInt(v); return },
+				"backoff":     func(v any) (err error) { cfg.RetryBackoff, err = asDuration(v); return },
+			})
+		case "rate_limit":
+			err = decodeTable(path, v, map[string]func(any) error{
+				"requests_per_second": func(v any) (err error) { cfg.RateLimit, err = asFloat(v); return },
+				"burst":               func(v any) (err error) { cfg.RateBurst, err = asInt(v); return },
+			})
+		default:
+			return &Error{Key: path, Msg: "unknown setting"}
+		}
+		if err != nil {
+			if ce, ok := err.(*Error); ok {
+				return ce
+			}
+			return &Error{Key: path, Msg: err.Error()}
+		}
+	}
+	return nil
+}
+
+func decodeTable(path string, v any, fields map[string]func(any) error) error {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return &Error{Key: path, Msg: "must be a table"}
+	}
+	for _, key := range sortedKeys(m) {
+		fv := m[key]
+		set, ok := fields[key]
+		if !ok {
+			return &Error{Key: path + "." + key, Msg: "unknown setting"}
+		}
+		if err := set(fv); err != nil {
+			return &Error{Key: path + "." + key, Msg: err.Error()}
+		}
+	}
+	return nil
+}
+
+func asString(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("must be a string, got %v", v)
+	}
+	return s, nil
+}
+
+func asStrings(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return splitList(v), nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("must be a list of strings, got %v", item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("must be a list of strings, got %v", v)
+}
+
+func asFloat(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("must be a number, got %v", v)
+}
+
+func asInt(v any) (int, error) {
+	f, err := asFloat(v)
+	if err != nil || f != float64(int(f)) {
+		return 0, fmt.Errorf("must be an integer, got %v", v)
+	}
+	return int(f), nil
+}
+
+func asDuration(v any) (time.Duration, error) {
+	switch v := v.(type) {
+	case string:
+		return parseDuration(v)
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	}
+	return 0, fmt.Errorf("must be a duration, got %v", v)
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by config files: tables and
+// dotted table headers, dotted keys, basic and literal strings, integers,
+// floats, booleans, single-line arrays and inline tables. Multi-line
+// strings, arrays of tables and dates are not supported.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	current := root
+
+	for i, raw := range strings.Split(string(data), "\n") {
+		num := i + 1
+		line := strings.TrimSpace(stripComment(strings.TrimRight(raw, "\r")))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", num)
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", num)
+			}
+			path, err := tomlKeyPath(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			t, err := tomlTable(root, path)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			current = t
+			continue
+		}
+
+		eq := tomlIndexUnquoted(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", num)
+		}
+		path, err := tomlKeyPath(line[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		v, err := tomlValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		if err := tomlSet(current, path, v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+	}
+	return root, nil
+}
+
+// tomlKeyPath splits a possibly dotted and quoted key into its parts.
+func tomlKeyPath(s string) ([]string, error) {
+	var path []string
+	for _, part := range tomlSplit(strings.TrimSpace(s), '.') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty key in %q", s)
+		}
+		if isQuoted(part) {
+			v, err := tomlValue(part)
+			if err != nil {
+				return nil, err
+			}
+			part = v.(string)
+		}
+		path = append(path, part)
+	}
+	return path, nil
+}
+
+// tomlTable returns the table at path, creating it as needed.
+func tomlTable(root map[string]any, path []string) (map[string]any, error) {
+	t := root
+	for _, key := range path {
+		next, ok := t[key]
+		if !ok {
+			m := map[string]any{}
+			t[key] = m
+			t = m
+			continue
+		}
+		m, ok := next.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("key %q is already defined as a value", key)
+		}
+		t = m
+	}
+	return t, nil
+}
+
+func tomlSet(t map[string]any, path []string, v any) error {
+	parent, err := tomlTable(t, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	key := path[len(path)-1]
+	if _, dup := parent[key]; dup {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	parent[key] = v
+	return nil
+}
+
+// tomlValue parses a single TOML value.
+func tomlValue(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		list := []any{}
+		for _, item := range tomlSplit(s[1:len(s)-1], ',') {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue // trailing comma
+			}
+			v, err := tomlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(s, "{"):
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		t := map[string]any{}
+		for _, field := range tomlSplit(s[1:len(s)-1], ',') {
+			if strings.TrimSpace(field) == "" {
+				continue
+			}
+			eq := tomlIndexUnquoted(field, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("expected \"key = value\" in inline table")
+			}
+			path, err := tomlKeyPath(field[:eq])
+			if err != nil {
+				return nil, err
+			}
+			v, err := tomlValue(strings.TrimSpace(field[eq+1:]))
+			if err != nil {
+				return nil, err
+			}
+			if err := tomlSet(t, path, v); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	}
+
+	num := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.ParseInt(num, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q (strings must be quoted)", s)
+}
+
+// tomlSplit splits s on sep where sep is not inside quotes, brackets or
+// braces.
+func tomlSplit(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// tomlIndexUnquoted returns the index of the first c outside quotes.
+func tomlIndexUnquoted(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == c:
+			return i
+		}
+	}
+	return -1
+}
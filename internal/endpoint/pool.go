@@ -0,0 +1,210 @@
+// Package endpoint tracks the health of a set of API base URLs and orders
+// them for failover. It is shared by the gatsbie and target clients.
+package endpoint
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultProbeInterval is how often endpoints are health checked when the
+// caller does not configure an interval.
+const DefaultProbeInterval = 30 * time.Second
+
+// ProbeFunc checks a single base URL and returns an error if it is unhealthy.
+type ProbeFunc func(ctx context.Context, baseURL string) error
+
+// Status is a point-in-time view of an endpoint.
+type Status struct {
+	URL       string
+	Healthy   bool
+	Latency   time.Duration
+	LastError error
+	CheckedAt time.Time
+}
+
+type state struct {
+	Status
+	index int
+}
+
+// Pool holds a set of endpoints and their observed health.
+type Pool struct {
+	mu     sync.Mutex
+	states []*state
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a pool for the given base URLs. All endpoints start out
+// healthy and are ordered as given until latency data is available.
+func New(urls []string) *Pool {
+	p := &Pool{stop: make(chan struct{})}
+	for i, u := range urls {
+		p.states = append(p.states, &state{
+			Status: Status{URL: u, Healthy: true},
+			index:  i,
+		})
+	}
+	return p
+}
+
+// Len returns the number of endpoints in the pool.
+func (p *Pool) Len() int {
+	return len(p.states)
+}
+
+// Start probes every endpoint immediately and then once per interval until
+// Close is called. It is a no-op for pools with fewer than two endpoints,
+// since there is nothing to route between.
+func (p *Pool) Start(interval time.Duration, timeout time.Duration, probe ProbeFunc) {
+	if len(p.states) < 2 || probe == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	if timeout <= 0 || timeout > interval {
+		timeout = interval
+	}
+
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.probeAll(timeout, probe)
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Pool) probeAll(timeout time.Duration, probe ProbeFunc) {
+	var wg sync.WaitGroup
+	for _, s := range p.states {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := probe(ctx, url)
+			if err != nil {
+				p.MarkFailure(url, err)
+				return
+			}
+			p.MarkSuccess(url, time.Since(start))
+		}(s.URL)
+	}
+	wg.Wait()
+}
+
+// Close stops background probing. It is safe to call more than once.
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		if p.done != nil {
+			<-p.done
+		}
+	})
+}
+
+// Ordered returns the endpoint URLs in the order they should be tried:
+// healthy endpoints first, fastest first, followed by unhealthy ones so
+// that a request still has somewhere to go if every probe failed.
+func (p *Pool) Ordered() []string {
+	p.mu.Lock()
+	sorted := make([]state, len(p.states))
+	for i, s := range p.states {
+		sorted[i] = *s
+	}
+	p.mu.Unlock()
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Healthy != b.Healthy {
+			return a.Healthy
+		}
+		if a.Healthy && a.Latency != b.Latency {
+			// Endpoints that have been measured go ahead of ones that
+			// have not; unmeasured endpoints keep their configured order.
+			if a.Latency == 0 || b.Latency == 0 {
+				return b.Latency == 0
+			}
+			return a.Latency < b.Latency
+		}
+		return a.index < b.index
+	})
+
+	urls := make([]string, len(sorted))
+	for i, s := range sorted {
+		urls[i] = s.URL
+	}
+	return urls
+}
+
+// MarkSuccess records a successful probe against url that took latency.
+// Only probes feed the latency used to order endpoints: calls take as
+// long as the task they solve, which says little about the endpoint.
+func (p *Pool) MarkSuccess(url string, latency time.Duration) {
+	p.update(url, func(s *state) {
+		s.Healthy = true
+		s.LastError = nil
+		if latency > 0 {
+			if s.Latency == 0 {
+				s.Latency = latency
+			} else {
+				// Exponentially weighted so a single slow call does not
+				// flip the routing order.
+				s.Latency = (s.Latency*7 + latency) / 8
+			}
+		}
+	})
+}
+
+// MarkHealthy records a successful call against url without a latency
+// sample.
+func (p *Pool) MarkHealthy(url string) {
+	p.MarkSuccess(url, 0)
+}
+
+// MarkFailure records a connection error or server failure against url.
+func (p *Pool) MarkFailure(url string, err error) {
+	p.update(url, func(s *state) {
+		s.Healthy = false
+		s.LastError = err
+	})
+}
+
+func (p *Pool) update(url string, fn func(*state)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.states {
+		if s.URL == url {
+			fn(s)
+			s.CheckedAt = time.Now()
+			return
+		}
+	}
+}
+
+// Statuses returns a snapshot of every endpoint in configured order.
+func (p *Pool) Statuses() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Status, len(p.states))
+	for i, s := range p.states {
+		out[i] = s.Status
+	}
+	return out
+}
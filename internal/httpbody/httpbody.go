@@ -0,0 +1,93 @@
+// Package httpbody reads HTTP response bodies with size limits.
+package httpbody
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// DefaultMaxSize is the default limit on response bodies.
+const DefaultMaxSize = 10 << 20
+
+// ErrTooLarge is returned when a body exceeds its limit.
+var ErrTooLarge = errors.New("response body too large")
+
+// Reader reads at most a fixed number of bytes from a body and fails with
+// ErrTooLarge if the body is longer. It records the first error from the
+// body so that callers decoding from it can tell a failed read from
+// malformed content.
+type Reader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+// NewReader returns a Reader that allows up to limit bytes from r.
+func NewReader(r io.Reader, limit int64) *Reader {
+	return &Reader{r: r, remaining: limit}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.remaining <= 0 {
+		// Only fail if there really is more data.
+		var b [1]byte
+		n, err := r.r.Read(b[:])
+		if n > 0 {
+			r.err = ErrTooLarge
+			return 0, r.err
+		}
+		if err != nil && err != io.EOF {
+			r.err = err
+		}
+		if err == nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// Err returns the first error other than io.EOF returned by the body, or
+// ErrTooLarge if the limit was exceeded.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// ReadPrefix reads up to limit bytes from r and reports whether the body
+// was longer.
+func ReadPrefix(r io.Reader, limit int64) (data []byte, truncated bool, err error) {
+	data, err = io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(data)) > limit {
+		return data[:limit], true, err
+	}
+	return data, false, err
+}
+
+// Snippet returns data as a string of at most n bytes, cut at a UTF-8
+// boundary and marked when shortened, for including a body in an error
+// message.
+func Snippet(data []byte, n int, truncated bool) string {
+	if len(data) > n {
+		for n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		data, truncated = data[:n], true
+	}
+	if truncated {
+		return string(data) + "... (truncated)"
+	}
+	return string(data)
+}
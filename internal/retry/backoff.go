@@ -0,0 +1,46 @@
+// Package retry holds the backoff schedule shared by the gatsbie and target
+// clients.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// maxBackoff caps the delay between attempts.
+const maxBackoff = 30 * time.Second
+
+// Backoff returns the delay before retry number attempt (starting at 1):
+// base doubled per attempt, capped at 30 seconds, with up to 20% jitter so
+// that many clients do not retry in lockstep.
+func Backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 || attempt < 1 {
+		return 0
+	}
+	d := base
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5 + 1))
+	return d + jitter
+}
+
+// Sleep waits for d or until ctx is done, returning ctx.Err() in the
+// latter case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package gatsbie
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultKeyCooldown = 10 * time.Minute
+
+// ErrNoAvailableAPIKey is returned when every configured API key is
+// disabled or cooling down after running out of credits.
+var ErrNoAvailableAPIKey = errors.New("gatsbie: no API key available")
+
+// WeightedAPIKey is an API key with a relative selection weight.
+type WeightedAPIKey struct {
+	Key    string
+	Weight int
+}
+
+// apiKeyState tracks rotation state for a single key.
+type apiKeyState struct {
+	key          string
+	weight       int
+	current      int
+	coolingUntil time.Time
+	disabled     bool
+}
+
+// keyPool selects API keys for outgoing requests. A key that runs out of
+// credits is skipped until its cooldown expires and a key that fails
+// authentication is skipped for the lifetime of the client. A pool with a
+// single key never takes it out of rotation, so callers that use one key
+// keep getting the API's errors instead of ErrNoAvailableAPIKey.
+type keyPool struct {
+	mu       sync.Mutex
+	keys     []*apiKeyState
+	weighted bool
+	cooldown time.Duration
+	next     int
+}
+
+func newKeyPool(keys []WeightedAPIKey, weighted bool, cooldown time.Duration) *keyPool {
+	if cooldown <= 0 {
+		cooldown = defaultKeyCooldown
+	}
+	p := &keyPool{weighted: weighted, cooldown: cooldown}
+	for _, k := range keys {
+		w := k.Weight
+		if w <= 0 {
+			w = 1
+		}
+		p.keys = append(p.keys, &apiKeyState{key: k.Key, weight: w})
+	}
+	return p
+}
+
+// pick returns the next key to use.
+func (p *keyPool) pick() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 1 {
+		return p.keys[0].key, nil
+	}
+
+	now := time.Now()
+	var available []*apiKeyState
+	for _, k := range p.keys {
+		if !k.disabled && !now.Before(k.coolingUntil) {
+			available = append(available, k)
+		}
+	}
+	if len(available) == 0 {
+		return "", ErrNoAvailableAPIKey
+	}
+
+	if p.weighted {
+		// Smooth weighted round-robin: deterministic and spreads heavier
+		// keys evenly instead of in bursts.
+		total := 0
+		var best *apiKeyState
+		for _, k := range available {
+			k.current += k.weight
+			total += k.weight
+			if best == nil || k.current > best.current {
+				best = k
+			}
+		}
+		best.current -= total
+		return best.key, nil
+	}
+
+	k := available[p.next%len(available)]
+	p.next++
+	return k.key, nil
+}
+
+// coolDown takes key out of rotation until the cooldown expires.
+func (p *keyPool) coolDown(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.key == key {
+			k.coolingUntil = time.Now().Add(p.cooldown)
+		}
+	}
+}
+
+// disable takes key out of rotation permanently.
+func (p *keyPool) disable(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.key == key {
+			k.disabled = true
+		}
+	}
+}
+
+// size returns the number of configured keys.
+func (p *keyPool) size() int {
+	return len(p.keys)
+}
+
+// live returns the first key that has not been disabled, or "" if there is
+// none. It is used for health probes and does not advance the rotation.
+func (p *keyPool) live() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if !k.disabled {
+			return k.key
+		}
+	}
+	return ""
+}
+
+// maskAPIKey returns a form of key that is safe to log and export in
+// metrics: the prefix and the last four characters.
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	prefix := ""
+	if len(key) > 5 && key[:5] == "gats_" {
+		prefix = "gats_"
+	}
+	return prefix + "…" + key[len(key)-4:]
+}
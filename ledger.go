@@ -0,0 +1,123 @@
+package gatsbie
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// solvePathPrefix is the path prefix of solve endpoints. The rest of the
+// path is the task type recorded in the ledger.
+const solvePathPrefix = "/v1/solve/"
+
+// LedgerEntry records the outcome of one solve call. Retries within the
+// call share its idempotency key and are not charged twice, so they are
+// not recorded separately.
+type LedgerEntry struct {
+	Time           time.Time         `json:"time"`
+	Task           string            `json:"task"` // task type, such as "turnstile"
+	TaskID         string            `json:"task_id,omitempty"`
+	Service        string            `json:"service,omitempty"`
+	Success        bool              `json:"success"`
+	Cost           float64           `json:"cost"`
+	SolveTime      float64           `json:"solve_time,omitempty"` // milliseconds, as reported by the API
+	Duration       time.Duration     `json:"duration"`             // wall time of the call, retries included
+	ErrorCode      string            `json:"error_code,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+// Ledger receives an entry for every solve call, successful or not. The
+// ledger package provides JSONL and SQLite implementations.
+//
+// Record is called synchronously once the call has finished, with a
+// context that is not cancelled when the call's context is. Errors from
+// Record do not fail the solve; they are passed to the hook set with
+// WithLedgerErrorHook, and are dropped if there is none.
+type Ledger interface {
+	Record(ctx context.Context, entry LedgerEntry) error
+}
+
+// WithLedger sets a ledger that records every solve call.
+func WithLedger(ledger Ledger) Option {
+	return func(o *options) {
+		o.ledger = ledger
+	}
+}
+
+// LedgerErrorHook receives an entry the ledger failed to record and the
+// error Record returned. Hooks are called synchronously and must not
+// block.
+type LedgerErrorHook func(entry LedgerEntry, err error)
+
+// WithLedgerErrorHook sets a hook that is called when the ledger fails to
+// record an entry, so that lost entries can be logged or retried.
+func WithLedgerErrorHook(hook LedgerErrorHook) Option {
+	return func(o *options) {
+		o.ledgerErr = hook
+	}
+}
+
+type ledgerLabelsContextKey struct{}
+
+// WithLedgerLabels returns a context whose solve calls are recorded with
+// labels, such as a customer or job name, in addition to any labels
+// already set on ctx. Later values win for the same key.
+func WithLedgerLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := make(map[string]string, len(labels))
+	for k, v := range LedgerLabelsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, ledgerLabelsContextKey{}, merged)
+}
+
+// LedgerLabelsFromContext returns the labels set with WithLedgerLabels.
+// The map must not be modified.
+func LedgerLabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(ledgerLabelsContextKey{}).(map[string]string)
+	return labels
+}
+
+// ledgerResult is implemented by responses that carry solve accounting.
+type ledgerResult interface {
+	fillLedgerEntry(e *LedgerEntry)
+}
+
+func (r *SolveResponse[T]) fillLedgerEntry(e *LedgerEntry) {
+	e.Success = r.Success
+	e.TaskID = r.TaskID
+	e.Service = r.Service
+	e.Cost = r.Cost
+	e.SolveTime = r.SolveTime
+}
+
+// record sends the outcome of a solve call to the ledger, if one is set.
+func (c *Client) record(ctx context.Context, r *request, start time.Time, result any, err error) {
+	if c.ledger == nil || !strings.HasPrefix(r.path, solvePathPrefix) {
+		return
+	}
+	e := LedgerEntry{
+		Time:           start.UTC(),
+		Task:           strings.TrimPrefix(r.path, solvePathPrefix),
+		Duration:       time.Since(start),
+		IdempotencyKey: r.idemKey,
+		Labels:         LedgerLabelsFromContext(ctx),
+	}
+	if err != nil {
+		e.Error = err.Error()
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			e.ErrorCode = apiErr.Code
+		}
+	} else if res, ok := result.(ledgerResult); ok {
+		res.fillLedgerEntry(&e)
+	}
+	if err := c.ledger.Record(context.WithoutCancel(ctx), e); err != nil && c.ledgerErr != nil {
+		c.ledgerErr(e, err)
+	}
+}
@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// JSONL is a Ledger that appends one JSON object per entry to a file.
+// Queries read the whole file.
+type JSONL struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenJSONL opens the ledger file at path for appending, creating it if
+// needed.
+func OpenJSONL(path string) (*JSONL, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+	return &JSONL{path: path, f: f}, nil
+}
+
+// Record appends entry to the file. It implements gatsbie.Ledger.
+func (l *JSONL) Record(ctx context.Context, entry gatsbie.LedgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("ledger: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("ledger: %s is closed", l.path)
+	}
+	// A single write keeps lines whole when several processes append to
+	// the same file.
+	if _, err := l.f.Write(data); err != nil {
+		return fmt.Errorf("ledger: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (l *JSONL) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Entries returns the entries recorded in r, in file order.
+func (l *JSONL) Entries(ctx context.Context, r Range) ([]gatsbie.LedgerEntry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+	defer f.Close()
+
+	var entries []gatsbie.LedgerEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e gatsbie.LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("ledger: %s:%d: %w", l.path, line, err)
+		}
+		if r.contains(e.Time) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// CostByTask implements Querier.
+func (l *JSONL) CostByTask(ctx context.Context, r Range) ([]Summary, error) {
+	return l.summarize(ctx, r, byTask)
+}
+
+// CostByLabel implements Querier.
+func (l *JSONL) CostByLabel(ctx context.Context, label string, r Range) ([]Summary, error) {
+	return l.summarize(ctx, r, byLabel(label))
+}
+
+// CostByDay implements Querier.
+func (l *JSONL) CostByDay(ctx context.Context, r Range) ([]Summary, error) {
+	return l.summarize(ctx, r, byDay)
+}
+
+func (l *JSONL) summarize(ctx context.Context, r Range, key func(gatsbie.LedgerEntry) string) ([]Summary, error) {
+	entries, err := l.Entries(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(entries, key), nil
+}
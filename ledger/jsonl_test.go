@@ -0,0 +1,161 @@
+package ledger_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+	"github.com/jaygatsbie/gatsbiesdk-go/ledger"
+)
+
+func openJSONL(t *testing.T) *ledger.JSONL {
+	t.Helper()
+	l, err := ledger.OpenJSONL(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func day(d, hour int) time.Time {
+	return time.Date(2024, time.March, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestJSONLQueries(t *testing.T) {
+	ctx := context.Background()
+	l := openJSONL(t)
+	entries := []gatsbie.LedgerEntry{
+		{Time: day(1, 9), Task: "turnstile", Success: true, Cost: 0.002, Labels: map[string]string{"team": "checkout"}},
+		{Time: day(1, 23), Task: "akamai", Success: true, Cost: 0.01, Labels: map[string]string{"team": "search"}},
+		{Time: day(2, 0), Task: "turnstile", Success: false, ErrorCode: gatsbie.ErrCodeSolveFailed},
+		{Time: day(2, 5), Task: "turnstile", Success: true, Cost: 0.002, Labels: map[string]string{"team": "checkout"}},
+		{Time: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), Task: "akamai", Success: true, Cost: 1},
+	}
+	for _, e := range entries {
+		if err := l.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	march := ledger.Month(day(15, 12))
+
+	byTask, err := l.CostByTask(ctx, march)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ledger.Summary{
+		{Key: "akamai", Solves: 1, Cost: 0.01},
+		{Key: "turnstile", Solves: 3, Failures: 1, Cost: 0.004},
+	}
+	if !reflect.DeepEqual(byTask, want) {
+		t.Errorf("CostByTask = %+v, want %+v", byTask, want)
+	}
+
+	byLabel, err := l.CostByLabel(ctx, "team", march)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "", Solves: 1, Failures: 1},
+		{Key: "checkout", Solves: 2, Cost: 0.004},
+		{Key: "search", Solves: 1, Cost: 0.01},
+	}
+	if !reflect.DeepEqual(byLabel, want) {
+		t.Errorf("CostByLabel = %+v, want %+v", byLabel, want)
+	}
+
+	byDay, err := l.CostByDay(ctx, ledger.Range{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "2024-03-01", Solves: 2, Cost: 0.012},
+		{Key: "2024-03-02", Solves: 2, Failures: 1, Cost: 0.002},
+		{Key: "2024-04-01", Solves: 1, Cost: 1},
+	}
+	if !reflect.DeepEqual(byDay, want) {
+		t.Errorf("CostByDay = %+v, want %+v", byDay, want)
+	}
+}
+
+func TestJSONLRecordsClientCalls(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "tok"}).WithCost(0.003))
+	srv.Handle(gatsbietest.TaskAkamai, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no luck"))
+	l := openJSONL(t)
+	client := srv.Client(gatsbie.WithLedger(l))
+
+	ctx := gatsbie.WithLedgerLabels(context.Background(), map[string]string{"team": "checkout", "job": "a"})
+	ctx = gatsbie.WithLedgerLabels(ctx, map[string]string{"job": "b"})
+	resp, err := client.SolveTurnstile(ctx, &gatsbie.TurnstileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SolveAkamai(context.Background(), &gatsbie.AkamaiRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := client.Health(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l.Entries(context.Background(), ledger.Range{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (health checks are not recorded)", len(entries))
+	}
+
+	ok := entries[0]
+	if ok.Task != "turnstile" || !ok.Success || ok.Cost != 0.003 || ok.TaskID != resp.TaskID || ok.Service != resp.Service {
+		t.Errorf("success entry = %+v", ok)
+	}
+	if ok.IdempotencyKey != resp.IdempotencyKey {
+		t.Errorf("IdempotencyKey = %q, want %q", ok.IdempotencyKey, resp.IdempotencyKey)
+	}
+	if want := map[string]string{"team": "checkout", "job": "b"}; !reflect.DeepEqual(ok.Labels, want) {
+		t.Errorf("Labels = %v, want %v", ok.Labels, want)
+	}
+	if ok.Time.IsZero() || ok.Duration <= 0 {
+		t.Errorf("Time = %v, Duration = %v", ok.Time, ok.Duration)
+	}
+
+	failed := entries[1]
+	if failed.Task != "akamai" || failed.Success || failed.ErrorCode != gatsbie.ErrCodeSolveFailed || failed.Error == "" {
+		t.Errorf("failure entry = %+v", failed)
+	}
+}
+
+func TestJSONLClosed(t *testing.T) {
+	l := openJSONL(t)
+	l.Close()
+	if err := l.Record(context.Background(), gatsbie.LedgerEntry{Task: "turnstile"}); err == nil {
+		t.Error("Record after Close succeeded")
+	}
+}
+
+func TestLedgerErrorHook(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "tok"}))
+	l := openJSONL(t)
+	l.Close()
+
+	var (
+		lost []gatsbie.LedgerEntry
+		errs []error
+	)
+	client := srv.Client(gatsbie.WithLedger(l), gatsbie.WithLedgerErrorHook(func(e gatsbie.LedgerEntry, err error) {
+		lost = append(lost, e)
+		errs = append(errs, err)
+	}))
+	if _, err := client.SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{}); err != nil {
+		t.Fatalf("solve failed because the ledger did: %v", err)
+	}
+	if len(lost) != 1 || lost[0].Task != "turnstile" || !lost[0].Success || errs[0] == nil {
+		t.Errorf("hook got %+v, %v; want the turnstile entry and its error", lost, errs)
+	}
+}
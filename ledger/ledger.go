@@ -0,0 +1,111 @@
+// Package ledger stores solve records for cost accounting.
+//
+// Install a ledger on a client with gatsbie.WithLedger and label calls
+// with gatsbie.WithLedgerLabels:
+//
+//	l, err := ledger.OpenJSONL("solves.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer l.Close()
+//	client := gatsbie.NewClient(apiKey, gatsbie.WithLedger(l))
+//
+//	ctx = gatsbie.WithLedgerLabels(ctx, map[string]string{"team": "checkout"})
+//	client.SolveTurnstile(ctx, req)
+//
+// Both JSONL and SQLite implement Querier, which totals cost by task
+// type, label or day.
+package ledger
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// dayLayout formats the keys returned by CostByDay.
+const dayLayout = "2006-01-02"
+
+// Range selects entries with From <= Time < To. A zero bound is open.
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// Month returns the Range covering the calendar month containing t, in
+// t's location.
+func Month(t time.Time) Range {
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return Range{From: from, To: from.AddDate(0, 1, 0)}
+}
+
+func (r Range) contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+// Summary totals the entries in one group.
+type Summary struct {
+	Key      string  // task type, label value or UTC day (YYYY-MM-DD)
+	Solves   int     // number of solve calls
+	Failures int     // calls that returned an error
+	Cost     float64 // credits charged
+}
+
+// Querier totals recorded cost. Results are sorted by Key.
+type Querier interface {
+	// CostByTask groups entries by task type.
+	CostByTask(ctx context.Context, r Range) ([]Summary, error)
+	// CostByLabel groups entries by the value of label. Entries without
+	// the label are grouped under the empty key.
+	CostByLabel(ctx context.Context, label string, r Range) ([]Summary, error)
+	// CostByDay groups entries by UTC day.
+	CostByDay(ctx context.Context, r Range) ([]Summary, error)
+}
+
+// summarize groups entries by key.
+func summarize(entries []gatsbie.LedgerEntry, key func(gatsbie.LedgerEntry) string) []Summary {
+	groups := map[string]*Summary{}
+	for _, e := range entries {
+		k := key(e)
+		s, ok := groups[k]
+		if !ok {
+			s = &Summary{Key: k}
+			groups[k] = s
+		}
+		s.Solves++
+		if !e.Success {
+			s.Failures++
+		}
+		s.Cost += e.Cost
+	}
+
+	out := make([]Summary, 0, len(groups))
+	for _, s := range groups {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func byTask(e gatsbie.LedgerEntry) string {
+	return e.Task
+}
+
+func byDay(e gatsbie.LedgerEntry) string {
+	return e.Time.UTC().Format(dayLayout)
+}
+
+func byLabel(label string) func(gatsbie.LedgerEntry) string {
+	return func(e gatsbie.LedgerEntry) string {
+		return e.Labels[label]
+	}
+}
+
+var (
+	_ gatsbie.Ledger = (*JSONL)(nil)
+	_ gatsbie.Ledger = (*SQLite)(nil)
+	_ Querier        = (*JSONL)(nil)
+	_ Querier        = (*SQLite)(nil)
+)
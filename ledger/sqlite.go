@@ -0,0 +1,150 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+// timeLayout stores times as fixed-width UTC text, so that they sort and
+// compare correctly as strings.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+const createTable = `
+CREATE TABLE IF NOT EXISTS gatsbie_ledger (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	time            TEXT    NOT NULL,
+	task            TEXT    NOT NULL,
+	task_id         TEXT    NOT NULL DEFAULT '',
+	service         TEXT    NOT NULL DEFAULT '',
+	success         INTEGER NOT NULL,
+	cost            REAL    NOT NULL DEFAULT 0,
+	solve_time      REAL    NOT NULL DEFAULT 0,
+	duration_ns     INTEGER NOT NULL DEFAULT 0,
+	error_code      TEXT    NOT NULL DEFAULT '',
+	error           TEXT    NOT NULL DEFAULT '',
+	idempotency_key TEXT    NOT NULL DEFAULT '',
+	labels          TEXT
+);
+CREATE INDEX IF NOT EXISTS gatsbie_ledger_time ON gatsbie_ledger (time);
+`
+
+// SQLite is a Ledger that stores entries in the gatsbie_ledger table of a
+// SQLite database. Label queries use the JSON functions built into SQLite
+// 3.38 and later.
+//
+// The package does not bundle a driver, so that it adds no dependencies
+// to programs that only use JSONL; the caller opens db with a
+// database/sql SQLite driver of its choice.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite creates the ledger table in db if it does not exist. db must
+// use a SQLite driver registered by the caller, such as modernc.org/sqlite
+// for an embedded database without cgo:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	db, err := sql.Open("sqlite", "ledger.db")
+//	...
+//	l, err := ledger.OpenSQLite(ctx, db)
+//
+// The caller keeps ownership of db and closes it when done.
+func OpenSQLite(ctx context.Context, db *sql.DB) (*SQLite, error) {
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("ledger: create table: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Record inserts entry. It implements gatsbie.Ledger.
+func (l *SQLite) Record(ctx context.Context, entry gatsbie.LedgerEntry) error {
+	var labels any
+	if len(entry.Labels) > 0 {
+		data, err := json.Marshal(entry.Labels)
+		if err != nil {
+			return fmt.Errorf("ledger: %w", err)
+		}
+		labels = string(data)
+	}
+	_, err := l.db.ExecContext(ctx, `
+INSERT INTO gatsbie_ledger
+	(time, task, task_id, service, success, cost, solve_time, duration_ns, error_code, error, idempotency_key, labels)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		formatTime(entry.Time), entry.Task, entry.TaskID, entry.Service,
+		entry.Success, entry.Cost, entry.SolveTime, int64(entry.Duration),
+		entry.ErrorCode, entry.Error, entry.IdempotencyKey, labels)
+	if err != nil {
+		return fmt.Errorf("ledger: insert: %w", err)
+	}
+	return nil
+}
+
+// CostByTask implements Querier.
+func (l *SQLite) CostByTask(ctx context.Context, r Range) ([]Summary, error) {
+	return l.summarize(ctx, "task", nil, r)
+}
+
+// CostByLabel implements Querier.
+func (l *SQLite) CostByLabel(ctx context.Context, label string, r Range) ([]Summary, error) {
+	// Look the label up with json_each rather than a json_extract path:
+	// SQLite has no escape for a double quote inside a quoted path member,
+	// so a path cannot name every label.
+	return l.summarize(ctx, "COALESCE((SELECT value FROM json_each(labels) WHERE key = ?), '')", []any{label}, r)
+}
+
+// CostByDay implements Querier.
+func (l *SQLite) CostByDay(ctx context.Context, r Range) ([]Summary, error) {
+	return l.summarize(ctx, "substr(time, 1, 10)", nil, r)
+}
+
+// summarize groups rows in r by the SQL expression key, whose arguments
+// are keyArgs.
+func (l *SQLite) summarize(ctx context.Context, key string, keyArgs []any, r Range) ([]Summary, error) {
+	var (
+		where []string
+		args  = append([]any(nil), keyArgs...)
+	)
+	if !r.From.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, formatTime(r.From))
+	}
+	if !r.To.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, formatTime(r.To))
+	}
+	query := "SELECT " + key + " AS k, COUNT(*), SUM(CASE WHEN success THEN 0 ELSE 1 END), COALESCE(SUM(cost), 0) FROM gatsbie_ledger"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY k ORDER BY k"
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Summary
+	for rows.Next() {
+		var s Summary
+		if err := rows.Scan(&s.Key, &s.Solves, &s.Failures, &s.Cost); err != nil {
+			return nil, fmt.Errorf("ledger: query: %w", err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ledger: query: %w", err)
+	}
+	return out, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
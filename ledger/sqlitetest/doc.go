@@ -0,0 +1,6 @@
+// Package sqlitetest runs the ledger.SQLite tests against a real SQLite
+// database. It is a separate module so that the SQLite driver it needs,
+// modernc.org/sqlite, is not a dependency of the SDK:
+//
+//	cd ledger/sqlitetest && go test ./...
+package sqlitetest
@@ -0,0 +1,189 @@
+package sqlitetest_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+	"github.com/jaygatsbie/gatsbiesdk-go/ledger"
+
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) (*ledger.SQLite, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	l, err := ledger.OpenSQLite(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, db
+}
+
+func day(d, hour int) time.Time {
+	return time.Date(2024, time.March, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestSQLiteQueries(t *testing.T) {
+	ctx := context.Background()
+	l, _ := openSQLite(t)
+	entries := []gatsbie.LedgerEntry{
+		{Time: day(1, 9), Task: "turnstile", Success: true, Cost: 0.002, Labels: map[string]string{"team": "checkout"}},
+		{Time: day(1, 23), Task: "akamai", Success: true, Cost: 0.01, Labels: map[string]string{"team": "search", `a"b`: "quoted"}},
+		{Time: day(2, 0), Task: "turnstile", Success: false, ErrorCode: gatsbie.ErrCodeSolveFailed},
+		{Time: day(2, 5).In(time.FixedZone("EST", -5*60*60)), Task: "turnstile", Success: true, Cost: 0.002, Labels: map[string]string{"team": "checkout"}},
+		{Time: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), Task: "akamai", Success: true, Cost: 1},
+	}
+	for _, e := range entries {
+		if err := l.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	march := ledger.Month(day(15, 12))
+
+	byTask, err := l.CostByTask(ctx, march)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ledger.Summary{
+		{Key: "akamai", Solves: 1, Cost: 0.01},
+		{Key: "turnstile", Solves: 3, Failures: 1, Cost: 0.004},
+	}
+	if !reflect.DeepEqual(byTask, want) {
+		t.Errorf("CostByTask = %+v, want %+v", byTask, want)
+	}
+
+	byLabel, err := l.CostByLabel(ctx, "team", march)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "", Solves: 1, Failures: 1},
+		{Key: "checkout", Solves: 2, Cost: 0.004},
+		{Key: "search", Solves: 1, Cost: 0.01},
+	}
+	if !reflect.DeepEqual(byLabel, want) {
+		t.Errorf("CostByLabel = %+v, want %+v", byLabel, want)
+	}
+
+	quoted, err := l.CostByLabel(ctx, `a"b`, march)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "", Solves: 3, Failures: 1, Cost: 0.004},
+		{Key: "quoted", Solves: 1, Cost: 0.01},
+	}
+	if !reflect.DeepEqual(quoted, want) {
+		t.Errorf("CostByLabel with a quote = %+v, want %+v", quoted, want)
+	}
+
+	byDay, err := l.CostByDay(ctx, ledger.Range{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "2024-03-01", Solves: 2, Cost: 0.012},
+		{Key: "2024-03-02", Solves: 2, Failures: 1, Cost: 0.002},
+		{Key: "2024-04-01", Solves: 1, Cost: 1},
+	}
+	if !reflect.DeepEqual(byDay, want) {
+		t.Errorf("CostByDay = %+v, want %+v", byDay, want)
+	}
+
+	// From is inclusive and To exclusive.
+	byDay, err = l.CostByDay(ctx, ledger.Range{From: day(1, 23), To: day(2, 5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []ledger.Summary{
+		{Key: "2024-03-01", Solves: 1, Cost: 0.01},
+		{Key: "2024-03-02", Solves: 1, Failures: 1},
+	}
+	if !reflect.DeepEqual(byDay, want) {
+		t.Errorf("CostByDay in range = %+v, want %+v", byDay, want)
+	}
+}
+
+func TestSQLiteRecordsClientCalls(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskTurnstile, gatsbietest.Solution(gatsbie.TurnstileSolution{Token: "tok"}).WithCost(0.003))
+	srv.Handle(gatsbietest.TaskAkamai, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no luck"))
+	l, db := openSQLite(t)
+	client := srv.Client(gatsbie.WithLedger(l))
+
+	ctx := gatsbie.WithLedgerLabels(context.Background(), map[string]string{"team": "checkout"})
+	resp, err := client.SolveTurnstile(ctx, &gatsbie.TurnstileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SolveAkamai(context.Background(), &gatsbie.AkamaiRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var (
+		task, taskID, errorCode, labels string
+		success                         bool
+		cost                            float64
+		durationNS                      int64
+	)
+	err = db.QueryRow(`SELECT task, task_id, success, cost, duration_ns, error_code, labels FROM gatsbie_ledger WHERE task = 'turnstile'`).
+		Scan(&task, &taskID, &success, &cost, &durationNS, &errorCode, &labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taskID != resp.TaskID || !success || cost != 0.003 || durationNS <= 0 || errorCode != "" || labels != `{"team":"checkout"}` {
+		t.Errorf("success row = %q %q %v %v %v %q %q", task, taskID, success, cost, durationNS, errorCode, labels)
+	}
+
+	var failedLabels sql.NullString
+	err = db.QueryRow(`SELECT success, error_code, labels FROM gatsbie_ledger WHERE task = 'akamai'`).
+		Scan(&success, &errorCode, &failedLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success || errorCode != gatsbie.ErrCodeSolveFailed || failedLabels.Valid {
+		t.Errorf("failure row = %v %q %v", success, errorCode, failedLabels)
+	}
+
+	byTask, err := l.CostByTask(context.Background(), ledger.Range{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ledger.Summary{
+		{Key: "akamai", Solves: 1, Failures: 1},
+		{Key: "turnstile", Solves: 1, Cost: 0.003},
+	}
+	if !reflect.DeepEqual(byTask, want) {
+		t.Errorf("CostByTask = %+v, want %+v", byTask, want)
+	}
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	ctx := context.Background()
+	l, db := openSQLite(t)
+	if err := l.Record(ctx, gatsbie.LedgerEntry{Time: day(1, 0), Task: "turnstile", Success: true, Cost: 0.002}); err != nil {
+		t.Fatal(err)
+	}
+	// Opening an existing ledger keeps its rows.
+	l, err := ledger.OpenSQLite(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byDay, err := l.CostByDay(ctx, ledger.Range{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ledger.Summary{{Key: "2024-03-01", Solves: 1, Cost: 0.002}}; !reflect.DeepEqual(byDay, want) {
+		t.Errorf("CostByDay = %+v, want %+v", byDay, want)
+	}
+}
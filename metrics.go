@@ -0,0 +1,37 @@
+package gatsbie
+
+import "time"
+
+// RequestMetrics describes a single HTTP request attempt made by the client.
+type RequestMetrics struct {
+	Method     string
+	Path       string
+	Endpoint   string
+	APIKeyID   string // masked API key, safe to use as a metric label
+	StatusCode int    // zero if no response was received
+	Duration   time.Duration
+	Cost       float64 // credits charged, as reported by the API
+	ErrorCode  string  // APIError code, if any
+	Err        error
+}
+
+// MetricsHook receives RequestMetrics after every request attempt,
+// including failed attempts that were retried against another endpoint or
+// API key. Hooks are called synchronously and must not block.
+type MetricsHook func(RequestMetrics)
+
+// WithMetricsHook sets a hook that is called after every request attempt.
+func WithMetricsHook(hook MetricsHook) Option {
+	return func(o *options) {
+		o.metricsHook = hook
+	}
+}
+
+// billed is implemented by responses that report the credits charged.
+type billed interface {
+	billedCost() float64
+}
+
+func (r *SolveResponse[T]) billedCost() float64 {
+	return r.Cost
+}
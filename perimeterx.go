@@ -0,0 +1,124 @@
+package gatsbie
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PerimeterXBlock is a PerimeterX press-and-hold block, as served in the
+// JSON body of a blocked API call or in an HTML block page.
+type PerimeterXBlock struct {
+	AppID       string `json:"appId"`
+	JSClientSrc string `json:"jsClientSrc"`
+	VID         string `json:"vid"`
+	UUID        string `json:"uuid"`
+	HostURL     string `json:"hostUrl"`
+	BlockScript string `json:"blockScript"` // captcha.js, resolved against the page URL
+}
+
+var (
+	pxWindowVar     = regexp.MustCompile(`window\._px(\w+)\s*=\s*["']([^"']*)["']`)
+	pxCaptchaScript = regexp.MustCompile(`<script[^>]+src=["']([^"']*/captcha(?:/captcha)?\.js[^"']*)["']`)
+	pxCaptchaDiv    = regexp.MustCompile(`id=["']px-captcha["']`)
+)
+
+// ParsePerimeterXBlock finds a press-and-hold block in body, the response
+// served at pageURL. It understands the block JSON returned to API calls
+// and the HTML block page with its px-captcha element and window._px
+// variables. It reports false if body is not a PerimeterX block.
+func ParsePerimeterXBlock(body []byte, pageURL string) (*PerimeterXBlock, bool) {
+	var b PerimeterXBlock
+	if json.Unmarshal(body, &b) != nil || b.AppID == "" || b.BlockScript == "" {
+		b = PerimeterXBlock{}
+		if !pxCaptchaDiv.Match(body) {
+			return nil, false
+		}
+		for _, m := range pxWindowVar.FindAllSubmatch(body, -1) {
+			value := string(m[2])
+			switch string(m[1]) {
+			case "AppId":
+				b.AppID = value
+			case "JsClientSrc":
+				b.JSClientSrc = value
+			case "Vid":
+				b.VID = value
+			case "Uuid":
+				b.UUID = value
+			case "HostUrl":
+				b.HostURL = value
+			}
+		}
+		if m := pxCaptchaScript.FindSubmatch(body); m != nil {
+			b.BlockScript = strings.ReplaceAll(string(m[1]), "&amp;", "&")
+		}
+		if b.AppID == "" || b.BlockScript == "" {
+			return nil, false
+		}
+	}
+
+	if base, err := url.Parse(pageURL); err == nil {
+		if ref, err := url.Parse(b.BlockScript); err == nil {
+			b.BlockScript = base.ResolveReference(ref).String()
+		}
+	}
+	return &b, true
+}
+
+// HoldRequest returns a PerimeterXHoldRequest for the block.
+func (b *PerimeterXBlock) HoldRequest(proxy, targetURL string) *PerimeterXHoldRequest {
+	return &PerimeterXHoldRequest{
+		Proxy:        proxy,
+		TargetURL:    targetURL,
+		PxAppID:      b.AppID,
+		CaptchaJSURL: b.BlockScript,
+		UUID:         b.UUID,
+		VID:          b.VID,
+	}
+}
+
+// SolvePerimeterXAuto solves PerimeterX for req, escalating to the
+// press-and-hold challenge when blockedResp, the response to the request
+// being protected, is a hold block. If blockedResp is nil or not a hold
+// block, it calls SolvePerimeterX. Either way the cookies are returned as
+// a PerimeterXSolution.
+//
+// blockedResp's body is read and replaced.
+func (c *Client) SolvePerimeterXAuto(ctx context.Context, req *PerimeterXRequest, blockedResp *http.Response, opts ...CallOption) (*SolveResponse[PerimeterXSolution], error) {
+	if blockedResp == nil || blockedResp.Body == nil {
+		return c.SolvePerimeterX(ctx, req, opts...)
+	}
+	data, err := readBlockedBody(blockedResp, c.maxBodySize)
+	if err != nil {
+		return nil, fmt.Errorf("perimeterx: %w", err)
+	}
+	pageURL := req.TargetURL
+	if blockedResp.Request != nil && blockedResp.Request.URL != nil {
+		pageURL = blockedResp.Request.URL.String()
+	}
+	block, ok := ParsePerimeterXBlock(data, pageURL)
+	if !ok {
+		return c.SolvePerimeterX(ctx, req, opts...)
+	}
+
+	resp, err := c.SolvePerimeterXHold(ctx, block.HoldRequest(req.Proxy, req.TargetURL), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SolveResponse[PerimeterXSolution]{
+		Success:        resp.Success,
+		TaskID:         resp.TaskID,
+		Service:        resp.Service,
+		Solution:       PerimeterXSolution(resp.Solution),
+		Cost:           resp.Cost,
+		SolveTime:      resp.SolveTime,
+		IdempotencyKey: resp.IdempotencyKey,
+		RawSolution:    resp.RawSolution,
+		Raw:            resp.Raw,
+		ReceivedAt:     resp.ReceivedAt,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package gatsbie_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+const pxBlockScript = "https://www.example.com/u6b0qd2S/captcha/captcha.js?a=c&u=a3c5e9f0-91d3-11ee-8c99-0242ac120002&v=5f0a7c2e-91d3-11ee-b9d1-0242ac120002&m=0"
+
+func TestParsePerimeterXBlock(t *testing.T) {
+	want := gatsbie.PerimeterXBlock{
+		AppID:       "PXu6b0qd2S",
+		JSClientSrc: "/u6b0qd2S/init.js",
+		VID:         "5f0a7c2e-91d3-11ee-b9d1-0242ac120002",
+		UUID:        "a3c5e9f0-91d3-11ee-8c99-0242ac120002",
+		HostURL:     "/u6b0qd2S/xhr",
+		BlockScript: pxBlockScript,
+	}
+	for _, fixture := range []string{"block.json", "block.html"} {
+		t.Run(fixture, func(t *testing.T) {
+			got, ok := gatsbie.ParsePerimeterXBlock(readFixture(t, "perimeterx", fixture), "https://www.example.com/api/cart")
+			if !ok || *got != want {
+				t.Errorf("ParsePerimeterXBlock = %+v, %v\nwant %+v", got, ok, want)
+			}
+		})
+	}
+
+	for _, body := range []string{`{"ok":true}`, `<html><body>Hello</body></html>`} {
+		if _, ok := gatsbie.ParsePerimeterXBlock([]byte(body), "https://www.example.com/"); ok {
+			t.Errorf("ParsePerimeterXBlock accepted %s", body)
+		}
+	}
+}
+
+func pxBlockedResponse(t *testing.T, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "https://www.example.com/api/cart", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Response{
+		StatusCode: http.StatusForbidden,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestSolvePerimeterXAuto(t *testing.T) {
+	req := &gatsbie.PerimeterXRequest{
+		Proxy:           testProxy,
+		TargetURL:       "https://www.example.com/",
+		PerimeterXJSURL: "https://client.px-cloud.net/PXu6b0qd2S/main.min.js",
+		PxAppID:         "PXu6b0qd2S",
+	}
+
+	t.Run("escalates to hold", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		resp, err := srv.Client().SolvePerimeterXAuto(context.Background(), req,
+			pxBlockedResponse(t, readFixture(t, "perimeterx", "block.json")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.ExpectField(gatsbietest.TaskPerimeterXHold, "pxAppId", "PXu6b0qd2S")
+		srv.ExpectField(gatsbietest.TaskPerimeterXHold, "captcha_js_url", pxBlockScript)
+		srv.ExpectField(gatsbietest.TaskPerimeterXHold, "uuid", "a3c5e9f0-91d3-11ee-8c99-0242ac120002")
+		srv.ExpectField(gatsbietest.TaskPerimeterXHold, "target_url", "https://www.example.com/")
+		if n := len(srv.Requests(gatsbietest.TaskPerimeterX)); n != 0 {
+			t.Errorf("invisible solved %d times", n)
+		}
+		if resp.Solution.Cookies.Px3 != "test-hold-px3" {
+			t.Errorf("Px3 = %q", resp.Solution.Cookies.Px3)
+		}
+	})
+
+	t.Run("not a block", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		blocked := pxBlockedResponse(t, []byte(`{"error":"rate limited"}`))
+		if _, err := srv.Client().SolvePerimeterXAuto(context.Background(), req, blocked); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(srv.Requests(gatsbietest.TaskPerimeterX)); n != 1 {
+			t.Errorf("invisible solved %d times, want 1", n)
+		}
+		if body, _ := io.ReadAll(blocked.Body); string(body) != `{"error":"rate limited"}` {
+			t.Errorf("body not restored: %q", body)
+		}
+	})
+
+	t.Run("no response", func(t *testing.T) {
+		srv := gatsbietest.NewServer(t)
+		if _, err := srv.Client().SolvePerimeterXAuto(context.Background(), req, nil); err != nil {
+			t.Fatal(err)
+		}
+		srv.ExpectField(gatsbietest.TaskPerimeterX, "perimeterx_js_url", req.PerimeterXJSURL)
+	})
+}
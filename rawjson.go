@@ -0,0 +1,168 @@
+package gatsbie
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// UnmarshalJSON decodes a solve response, keeping the raw solution and
+// body alongside the typed fields.
+func (r *SolveResponse[T]) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		Success   bool            `json:"success"`
+		TaskID    string          `json:"taskId"`
+		Service   string          `json:"service"`
+		Solution  json.RawMessage `json:"solution"`
+		Cost      float64         `json:"cost"`
+		SolveTime float64         `json:"solveTime"`
+
+		ReceivedAt time.Time `json:"receivedAt"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	var solution T
+	if len(wire.Solution) > 0 {
+		if err := json.Unmarshal(wire.Solution, &solution); err != nil {
+			return err
+		}
+	}
+
+	r.Success = wire.Success
+	r.TaskID = wire.TaskID
+	r.Service = wire.Service
+	r.Solution = solution
+	r.Cost = wire.Cost
+	r.SolveTime = wire.SolveTime
+	r.RawSolution = wire.Solution
+	r.Raw = append(json.RawMessage(nil), data...)
+	r.ReceivedAt = wire.ReceivedAt
+	return nil
+}
+
+// UnmarshalJSON decodes the cookies, collecting unknown ones in Extra.
+func (c *AkamaiCookies) UnmarshalJSON(data []byte) error {
+	type plain AkamaiCookies
+	extra, err := unmarshalExtra(data, (*plain)(c))
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the cookies, including those in Extra.
+func (c AkamaiCookies) MarshalJSON() ([]byte, error) {
+	type plain AkamaiCookies
+	return marshalExtra(plain(c), c.Extra)
+}
+
+// UnmarshalJSON decodes the cookies, collecting unknown ones in Extra.
+func (c *PerimeterXCookies) UnmarshalJSON(data []byte) error {
+	type plain PerimeterXCookies
+	extra, err := unmarshalExtra(data, (*plain)(c))
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the cookies, including those in Extra.
+func (c PerimeterXCookies) MarshalJSON() ([]byte, error) {
+	type plain PerimeterXCookies
+	return marshalExtra(plain(c), c.Extra)
+}
+
+// UnmarshalJSON decodes the cookies, collecting unknown ones in Extra.
+// A cf_clearance given as a Set-Cookie line is split into its value and
+// expiry attributes.
+func (c *CloudflareWAFCookies) UnmarshalJSON(data []byte) error {
+	type plain CloudflareWAFCookies
+	extra, err := unmarshalExtra(data, (*plain)(c))
+	c.Extra = extra
+	if err == nil {
+		c.splitClearance()
+	}
+	return err
+}
+
+// MarshalJSON encodes the cookies, including those in Extra. A
+// cf_clearance with expiry attributes is encoded as a Set-Cookie line.
+func (c CloudflareWAFCookies) MarshalJSON() ([]byte, error) {
+	type plain CloudflareWAFCookies
+	c.CfClearance = c.clearanceLine()
+	return marshalExtra(plain(c), c.Extra)
+}
+
+// UnmarshalJSON decodes the cookies, collecting unknown ones in Extra.
+func (c *CaptchaFoxCookies) UnmarshalJSON(data []byte) error {
+	type plain CaptchaFoxCookies
+	extra, err := unmarshalExtra(data, (*plain)(c))
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes the cookies, including those in Extra.
+func (c CaptchaFoxCookies) MarshalJSON() ([]byte, error) {
+	type plain CaptchaFoxCookies
+	return marshalExtra(plain(c), c.Extra)
+}
+
+// unmarshalExtra decodes data into v, a pointer to a struct, and returns
+// the object members that none of v's fields take.
+func unmarshalExtra(data []byte, v any) (map[string]any, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := jsonNames(reflect.TypeOf(v).Elem())
+	for key := range all {
+		// encoding/json matches field names case-insensitively.
+		for _, name := range known {
+			if strings.EqualFold(key, name) {
+				delete(all, key)
+				break
+			}
+		}
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// marshalExtra encodes v, a struct, with the members of extra added.
+// Fields of v win over extra members with the same name.
+func marshalExtra(v any, extra map[string]any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := all[key]; !ok {
+			all[key] = value
+		}
+	}
+	return json.Marshal(all)
+}
+
+// jsonNames returns the JSON member names of the fields of struct type t.
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case !f.IsExported() || name == "-":
+			continue
+		case name == "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
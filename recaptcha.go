@@ -0,0 +1,63 @@
+package gatsbie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultRecaptchaAttempts is the number of solves made to reach MinScore
+// when MaxAttempts is zero.
+const DefaultRecaptchaAttempts = 3
+
+// ErrScoreTooLow is returned when no reCAPTCHA solve reached MinScore
+// within MaxAttempts.
+var ErrScoreTooLow = errors.New("gatsbie: recaptcha score below minimum")
+
+// solveForScore calls solve until its solution scores at least minScore
+// or maxAttempts solves have been made. Solutions that report no score
+// are accepted, since there is nothing to check. Attempts and TotalCost
+// are set on the returned solution.
+//
+// If no solve reached minScore, the best one is returned along with an
+// error wrapping ErrScoreTooLow. If a solve after the first fails, the
+// best one so far is returned, with the attempts and cost spent, along
+// with an error wrapping both ErrScoreTooLow and the failure.
+func solveForScore(ctx context.Context, minScore float64, maxAttempts int, solve func(context.Context) (*SolveResponse[RecaptchaSolution], error)) (*SolveResponse[RecaptchaSolution], error) {
+	if minScore < 0 || minScore > 1 {
+		return nil, invalidRequest("recaptcha: min score must be between 0 and 1, got %v", minScore)
+	}
+	if minScore == 0 {
+		maxAttempts = 1
+	} else if maxAttempts <= 0 {
+		maxAttempts = DefaultRecaptchaAttempts
+	}
+
+	var best *SolveResponse[RecaptchaSolution]
+	var total float64
+	for n := 1; n <= maxAttempts; n++ {
+		resp, err := solve(attemptContext(ctx, n))
+		if err != nil {
+			if best == nil {
+				return nil, err
+			}
+			best.Solution.Attempts = n
+			best.Solution.TotalCost = total
+			return best, fmt.Errorf("%w: best %v of %v, attempt %d failed: %w", ErrScoreTooLow, *best.Solution.Score, minScore, n, err)
+		}
+		total += resp.Cost
+		resp.Solution.Attempts = n
+		resp.Solution.TotalCost = total
+
+		score := resp.Solution.Score
+		if score == nil || *score >= minScore {
+			return resp, nil
+		}
+		if best == nil || *score > *best.Solution.Score {
+			best = resp
+		}
+	}
+	best.Solution.Attempts = maxAttempts
+	best.Solution.TotalCost = total
+	return best, fmt.Errorf("%w: best %v of %v after %d attempts", ErrScoreTooLow, *best.Solution.Score, minScore, maxAttempts)
+}
@@ -0,0 +1,117 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func scored(token string, score float64) gatsbietest.Response {
+	return gatsbietest.Solution(gatsbie.RecaptchaSolution{Token: token, Score: &score}).WithCost(0.001)
+}
+
+func TestRecaptchaMinScore(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskRecaptcha, scored("low", 0.3), scored("high", 0.9))
+
+	resp, err := srv.Client().SolveRecaptcha(context.Background(), &gatsbie.RecaptchaRequest{
+		TargetURL: "https://example.com",
+		SiteKey:   "site-key",
+		Action:    "login",
+		MinScore:  0.7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Solution.Token != "high" || *resp.Solution.Score != 0.9 {
+		t.Errorf("Solution = %+v", resp.Solution)
+	}
+	if resp.Solution.Attempts != 2 || resp.Solution.TotalCost != 0.002 {
+		t.Errorf("Attempts = %d, TotalCost = %v, want 2, 0.002", resp.Solution.Attempts, resp.Solution.TotalCost)
+	}
+	srv.ExpectField(gatsbietest.TaskRecaptcha, "min_score", 0.7)
+	srv.ExpectNoField(gatsbietest.TaskRecaptcha, "max_attempts")
+}
+
+func TestRecaptchaMinScoreExhausted(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskRecaptchaEnterprise, scored("a", 0.3), scored("b", 0.5), scored("c", 0.1))
+
+	resp, err := srv.Client().SolveRecaptchaEnterprise(context.Background(), &gatsbie.RecaptchaEnterpriseRequest{
+		MinScore:    0.7,
+		MaxAttempts: 3,
+	})
+	if !errors.Is(err, gatsbie.ErrScoreTooLow) {
+		t.Fatalf("err = %v, want ErrScoreTooLow", err)
+	}
+	if resp == nil || resp.Solution.Token != "b" {
+		t.Fatalf("resp = %+v, want best solution b", resp)
+	}
+	if resp.Solution.Attempts != 3 || resp.Solution.TotalCost != 0.003 {
+		t.Errorf("Attempts = %d, TotalCost = %v, want 3, 0.003", resp.Solution.Attempts, resp.Solution.TotalCost)
+	}
+}
+
+func TestRecaptchaMinScoreAttemptFails(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskRecaptcha, scored("low", 0.3), gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no luck"))
+
+	resp, err := srv.Client().SolveRecaptcha(context.Background(), &gatsbie.RecaptchaRequest{MinScore: 0.7})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != gatsbie.ErrCodeSolveFailed || !errors.Is(err, gatsbie.ErrScoreTooLow) {
+		t.Fatalf("err = %v, want SOLVE_FAILED and ErrScoreTooLow", err)
+	}
+	if resp == nil || resp.Solution.Token != "low" {
+		t.Fatalf("resp = %+v, want the first solution", resp)
+	}
+	if resp.Solution.Attempts != 2 || resp.Solution.TotalCost != 0.001 {
+		t.Errorf("Attempts = %d, TotalCost = %v, want 2, 0.001", resp.Solution.Attempts, resp.Solution.TotalCost)
+	}
+}
+
+func TestRecaptchaMinScoreWithoutScore(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	resp, err := srv.Client().SolveRecaptcha(context.Background(), &gatsbie.RecaptchaRequest{MinScore: 0.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Solution.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1 when the API reports no score", resp.Solution.Attempts)
+	}
+}
+
+func TestRecaptchaAttemptIdempotencyKeys(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskRecaptcha, scored("low", 0.1), scored("high", 0.9))
+
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "job-42")
+	resp, err := srv.Client().SolveRecaptcha(ctx, &gatsbie.RecaptchaRequest{MinScore: 0.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests(gatsbietest.TaskRecaptcha)
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	if got := reqs[0].Header.Get("Idempotency-Key"); got != "job-42" {
+		t.Errorf("first attempt key = %q, want job-42", got)
+	}
+	if got := reqs[1].Header.Get("Idempotency-Key"); got != "job-42-2" {
+		t.Errorf("second attempt key = %q, want job-42-2", got)
+	}
+	if resp.IdempotencyKey != "job-42-2" {
+		t.Errorf("IdempotencyKey = %q", resp.IdempotencyKey)
+	}
+}
+
+func TestRecaptchaMinScoreOutOfRange(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	_, err := srv.Client().SolveRecaptcha(context.Background(), &gatsbie.RecaptchaRequest{MinScore: 7})
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want INVALID_REQUEST", err)
+	}
+}
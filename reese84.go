@@ -0,0 +1,228 @@
+package gatsbie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reese84 renewal timing. A token is renewed a fifth of its lifetime, at
+// most reese84RenewMargin, before it expires; a failed renewal is retried
+// after reese84RetryDelay, or sooner if the token expires first.
+const (
+	reese84RenewMargin = 30 * time.Second
+	reese84RetryDelay  = 10 * time.Second
+)
+
+// Reese84Session keeps a Reese84 token fresh. Start solves once and then
+// renews the token in the background before it expires, setting it as the
+// reese84 cookie in a cookie jar for the protected site.
+type Reese84Session struct {
+	client *Client
+	req    Reese84Request
+	jar    http.CookieJar
+	site   *url.URL
+	opts   []CallOption
+	solves int // successful solves, numbering the next for attemptContext; used only by renew
+
+	mu      sync.Mutex
+	resp    *SolveResponse[Reese84Solution]
+	err     error         // error of the last renewal, if it failed
+	started bool          // Start is running or has succeeded
+	done    chan struct{} // closed when background renewal stops
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// errReese84Started is returned by a second call to Start.
+var errReese84Started = errors.New("gatsbie: reese84 session already started")
+
+// NewReese84Session returns a session that solves req and stores the
+// token in jar for req.TargetURL, which is required. jar may be nil if
+// the caller applies Token itself.
+func (c *Client) NewReese84Session(req *Reese84Request, jar http.CookieJar, opts ...CallOption) (*Reese84Session, error) {
+	site, err := url.Parse(req.TargetURL)
+	if err != nil || site.Host == "" {
+		return nil, invalidRequest("reese84: session needs an absolute target URL, got %q", req.TargetURL)
+	}
+	return &Reese84Session{
+		client: c,
+		req:    *req,
+		jar:    jar,
+		site:   site,
+		opts:   opts,
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// Start solves the first token and starts renewing it in the background
+// until ctx is done or Close is called. It returns the error of the first
+// solve; later failures are reported by Err and retried. A key set on ctx
+// with WithIdempotencyKey is used for the first solve and suffixed with
+// -2, -3, ... for each renewal; a failed solve is retried with the same
+// key, so a solve the API charged for is not charged again.
+//
+// Start may be called again only if it failed.
+func (s *Reese84Session) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errReese84Started
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	if err := s.renew(ctx); err != nil {
+		s.mu.Lock()
+		s.started = false
+		s.mu.Unlock()
+		return err
+	}
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+	go func() {
+		defer close(done)
+		timer := time.NewTimer(s.nextRenewal())
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			s.renew(ctx)
+			timer.Reset(s.nextRenewal())
+		}
+	}()
+	return nil
+}
+
+// Close stops background renewal. It is safe to call more than once.
+func (s *Reese84Session) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.mu.Lock()
+		done := s.done
+		s.mu.Unlock()
+		if done != nil {
+			<-done
+		}
+	})
+}
+
+// Token returns the current token, or "" before the first solve.
+func (s *Reese84Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resp == nil {
+		return ""
+	}
+	return s.resp.Solution.Reese84
+}
+
+// Response returns the solve that produced the current token, or nil
+// before the first solve.
+func (s *Reese84Session) Response() *SolveResponse[Reese84Solution] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resp
+}
+
+// ExpiresAt returns when the current token must be renewed by, from its
+// renewInSec or Reese84Lifetime.
+func (s *Reese84Session) ExpiresAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resp == nil {
+		return time.Time{}
+	}
+	return s.resp.ExpiresAt()
+}
+
+// RenewAt returns when the session will next renew the token.
+func (s *Reese84Session) RenewAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resp == nil {
+		return time.Time{}
+	}
+	return renewAt(s.resp)
+}
+
+// Err returns the error of the last background renewal, or nil if it
+// succeeded. The previous token stays in use while renewal fails.
+func (s *Reese84Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Cookie returns the current token as the reese84 cookie.
+func (s *Reese84Session) Cookie() *http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resp == nil {
+		return nil
+	}
+	return s.cookie(s.resp)
+}
+
+func (s *Reese84Session) cookie(resp *SolveResponse[Reese84Solution]) *http.Cookie {
+	return &http.Cookie{
+		Name:   "reese84",
+		Value:  resp.Solution.Reese84,
+		Path:   "/",
+		Domain: strings.TrimPrefix(resp.Solution.CookieDomain, "."),
+	}
+}
+
+// renew solves a new token and stores it. The solve is numbered only once
+// it succeeds, so a retry reuses the failed attempt's idempotency key.
+func (s *Reese84Session) renew(ctx context.Context) error {
+	req := s.req
+	resp, err := s.client.SolveReese84(attemptContext(ctx, s.solves+1), &req, s.opts...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err != nil {
+		return err
+	}
+	s.solves++
+	s.resp = resp
+	if s.jar != nil {
+		s.jar.SetCookies(s.site, []*http.Cookie{s.cookie(resp)})
+	}
+	return nil
+}
+
+// nextRenewal returns how long to wait before the next renewal.
+func (s *Reese84Session) nextRenewal() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		// Retry sooner if the current token expires first, but never
+		// spin once it has expired.
+		if left := s.resp.TimeLeft(); left > 0 && left < reese84RetryDelay {
+			return left
+		}
+		return reese84RetryDelay
+	}
+	return max(time.Until(renewAt(s.resp)), 0)
+}
+
+// renewAt returns when resp's token should be renewed.
+func renewAt(resp *SolveResponse[Reese84Solution]) time.Time {
+	expires := resp.ExpiresAt()
+	margin := min(expires.Sub(resp.ReceivedAt)/5, reese84RenewMargin)
+	return expires.Add(-margin)
+}
@@ -0,0 +1,218 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func reese84Token(token string, renewInSec int) gatsbietest.Response {
+	return gatsbietest.Solution(gatsbie.Reese84Solution{
+		Reese84:      token,
+		UserAgent:    gatsbietest.DefaultUserAgent,
+		RenewInSec:   renewInSec,
+		CookieDomain: ".example.com",
+	})
+}
+
+func jarValue(t *testing.T, jar *cookiejar.Jar, rawURL, name string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range jar.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestReese84Session(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, reese84Token("first", 600))
+	jar, _ := cookiejar.New(nil)
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{
+		Reese84JsUrl: "https://www.example.com/abc-def-ghi",
+		TargetURL:    "https://www.example.com/",
+	}, jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.Token() != "first" {
+		t.Errorf("Token() = %q", s.Token())
+	}
+	// The cookie domain covers subdomains of the protected site.
+	if got := jarValue(t, jar, "https://shop.example.com/cart", "reese84"); got != "first" {
+		t.Errorf("jar reese84 = %q, want first", got)
+	}
+	received := s.Response().ReceivedAt
+	if got, want := s.ExpiresAt(), received.Add(600*time.Second); !got.Equal(want) {
+		t.Errorf("ExpiresAt() = %v, want %v", got, want)
+	}
+	if got, want := s.RenewAt(), received.Add(570*time.Second); !got.Equal(want) {
+		t.Errorf("RenewAt() = %v, want %v", got, want)
+	}
+	srv.ExpectField(gatsbietest.TaskReese84, "target_url", "https://www.example.com/")
+}
+
+func TestReese84SessionRenews(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, reese84Token("first", 1), reese84Token("second", 600))
+	jar, _ := cookiejar.New(nil)
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{TargetURL: "https://www.example.com/"}, jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Token() != "second" {
+		if time.Now().After(deadline) {
+			t.Fatalf("token not renewed; Token() = %q, Err() = %v", s.Token(), s.Err())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := jarValue(t, jar, "https://www.example.com/", "reese84"); got != "second" {
+		t.Errorf("jar reese84 = %q, want second", got)
+	}
+
+	s.Close()
+	n := len(srv.Requests(gatsbietest.TaskReese84))
+	if n != 2 {
+		t.Errorf("solved %d times, want 2", n)
+	}
+}
+
+func TestReese84SessionStartError(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no token"))
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{TargetURL: "https://www.example.com/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var apiErr *gatsbie.APIError
+	if err := s.Start(context.Background()); !errors.As(err, &apiErr) || !apiErr.IsSolveFailed() {
+		t.Fatalf("Start() = %v, want SOLVE_FAILED", err)
+	}
+	if s.Token() != "" || s.Cookie() != nil {
+		t.Error("session has a token after a failed solve")
+	}
+}
+
+func TestReese84SessionNeedsTargetURL(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	_, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{}, nil)
+	var apiErr *gatsbie.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+		t.Fatalf("err = %v, want INVALID_REQUEST", err)
+	}
+}
+
+func TestReese84SessionIdempotencyKeys(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, reese84Token("first", 1), reese84Token("second", 1), reese84Token("third", 600))
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{TargetURL: "https://www.example.com/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "session-1")
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Token() != "third" {
+		if time.Now().After(deadline) {
+			t.Fatalf("token not renewed; Token() = %q, Err() = %v", s.Token(), s.Err())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.Close()
+
+	var keys []string
+	for _, r := range srv.Requests(gatsbietest.TaskReese84) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+	}
+	want := []string{"session-1", "session-1-2", "session-1-3"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %q, want %q", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("keys = %q, want %q", keys, want)
+			break
+		}
+	}
+}
+
+func TestReese84SessionRetryReusesKey(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, gatsbietest.Error(gatsbie.ErrCodeSolveFailed, "no token"), reese84Token("first", 600))
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{TargetURL: "https://www.example.com/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := gatsbie.WithIdempotencyKey(context.Background(), "session-1")
+	if err := s.Start(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start after a failed Start: %v", err)
+	}
+	for i, r := range srv.Requests(gatsbietest.TaskReese84) {
+		if got := r.Header.Get("Idempotency-Key"); got != "session-1" {
+			t.Errorf("request %d key = %q, want session-1 for a retry", i, got)
+		}
+	}
+}
+
+func TestReese84SessionStartTwice(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	srv.Handle(gatsbietest.TaskReese84, reese84Token("first", 600))
+
+	s, err := srv.Client().NewReese84Session(&gatsbie.Reese84Request{TargetURL: "https://www.example.com/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		time.Sleep(time.Millisecond)
+		s.Close()
+	}()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(context.Background()); err == nil {
+		t.Error("second Start succeeded")
+	}
+	<-closed
+	s.Close()
+	if n := len(srv.Requests(gatsbietest.TaskReese84)); n != 1 {
+		t.Errorf("solved %d times, want 1", n)
+	}
+}
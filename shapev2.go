@@ -0,0 +1,69 @@
+package gatsbie
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Validate checks the fields of r that the API would otherwise reject. It
+// returns an *APIError with code INVALID_REQUEST.
+func (r *ShapeV2Request) Validate() error {
+	if r.Timeout < 0 {
+		return invalidRequest("shape-v2: timeout must not be negative, got %d", r.Timeout)
+	}
+	if r.Country != "" && !isCountryCode(r.Country) {
+		return invalidRequest("shape-v2: country must be a two-letter ISO 3166-1 code, got %q", r.Country)
+	}
+	return nil
+}
+
+// ApplyHeaders sets the solution's headers on req, the protected API
+// call, replacing any existing values. Header names are kept exactly as
+// the API returned them rather than canonicalized. The cookie in Extra, if
+// any, is added to req's Cookie header.
+func (s ShapeV2Solution) ApplyHeaders(req *http.Request) {
+	for name, value := range s.Headers {
+		req.Header.Del(name)
+		req.Header[name] = []string{value}
+	}
+	if s.Extra.Cookie == "" {
+		return
+	}
+	if existing := req.Header.Get("Cookie"); existing != "" {
+		req.Header.Set("Cookie", existing+"; "+s.Extra.Cookie)
+	} else {
+		req.Header.Set("Cookie", s.Extra.Cookie)
+	}
+}
+
+// UnmarshalJSON decodes the extra data, collecting unknown members in
+// Other.
+func (e *ShapeV2Extra) UnmarshalJSON(data []byte) error {
+	type plain ShapeV2Extra
+	other, err := unmarshalExtra(data, (*plain)(e))
+	e.Other = other
+	return err
+}
+
+// MarshalJSON encodes the extra data, including the members in Other.
+func (e ShapeV2Extra) MarshalJSON() ([]byte, error) {
+	type plain ShapeV2Extra
+	return marshalExtra(plain(e), e.Other)
+}
+
+func isCountryCode(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidRequest returns a client-side INVALID_REQUEST error.
+func invalidRequest(format string, args ...any) error {
+	return &APIError{Code: ErrCodeInvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,69 @@
+package gatsbie_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestShapeV2Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  gatsbie.ShapeV2Request
+		ok   bool
+	}{
+		{"defaults", gatsbie.ShapeV2Request{}, true},
+		{"valid", gatsbie.ShapeV2Request{Country: "US", Timeout: 60}, true},
+		{"negative timeout", gatsbie.ShapeV2Request{Timeout: -1}, false},
+		{"long country", gatsbie.ShapeV2Request{Country: "usa"}, false},
+		{"digits", gatsbie.ShapeV2Request{Country: "u1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := gatsbietest.NewServer(t)
+			_, err := srv.Client().SolveShapeV2(context.Background(), &tt.req)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			var apiErr *gatsbie.APIError
+			if !errors.As(err, &apiErr) || !apiErr.IsInvalidRequest() {
+				t.Fatalf("err = %v, want invalid request", err)
+			}
+			if n := len(srv.Requests()); n != 0 {
+				t.Errorf("server saw %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestShapeV2ApplyHeaders(t *testing.T) {
+	sol := gatsbie.ShapeV2Solution{
+		Headers: map[string]string{"x-abc-a": "a", "X-Abc-B": "b"},
+		Extra:   gatsbie.ShapeV2Extra{Cookie: "shape=1"},
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/api/login", nil)
+	req.Header.Set("X-Abc-A", "stale")
+	req.Header.Set("Cookie", "session=abc")
+
+	sol.ApplyHeaders(req)
+
+	if got := req.Header["x-abc-a"]; len(got) != 1 || got[0] != "a" {
+		t.Errorf("x-abc-a = %v", got)
+	}
+	if _, ok := req.Header["X-Abc-A"]; ok {
+		t.Error("stale canonical header was not replaced")
+	}
+	if got := req.Header.Get("X-Abc-B"); got != "b" {
+		t.Errorf("X-Abc-B = %q", got)
+	}
+	if got := req.Header.Get("Cookie"); got != "session=abc; shape=1" {
+		t.Errorf("Cookie = %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package target
+
+import "time"
+
+// CallOption configures a single API call, overriding client settings for
+// that call only.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout time.Duration
+}
+
+// WithCallTimeout sets a deadline for the whole call, including any
+// retries and failover, replacing the client's per-request timeout.
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
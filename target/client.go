@@ -4,150 +4,397 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
+
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/endpoint"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/httpbody"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/ratelimit"
+	"github.com/jaygatsbie/gatsbiesdk-go/internal/retry"
 )
 
 const (
-	defaultBaseURL = "https://target.gatsbie.io"
-	defaultTimeout = 120 * time.Second
+	defaultBaseURL      = "https://target.gatsbie.io"
+	defaultTimeout      = 120 * time.Second
+	defaultRetryBackoff = time.Second
+
+	// errorBodyLimit bounds how much of an error response is read, and
+	// errorSnippetSize how much of a non-JSON one is quoted in the error.
+	errorBodyLimit   = 64 << 10
+	errorSnippetSize = 512
 )
 
+// ErrResponseTooLarge is returned, wrapped, when a response body exceeds
+// the limit set with WithMaxResponseSize.
+var ErrResponseTooLarge = httpbody.ErrTooLarge
+
 // Client is the Target API client.
 type Client struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	apiKey       string
+	httpClient   *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	defaultProxy string
+	maxBodySize  int64
+	endpoints    *endpoint.Pool
+	limiter      *ratelimit.Limiter
+}
+
+// options collects the settings made by Option functions. They are applied
+// together in NewClient, so the order options are passed in does not
+// matter.
+type options struct {
+	baseURLs            []string
+	httpClient          *http.Client
+	timeout             time.Duration
+	healthCheckInterval time.Duration
+	maxRetries          int
+	retryBackoff        time.Duration
+	rateLimit           float64
+	rateBurst           int
+	defaultProxy        string
+	maxBodySize         int64
 }
 
 // Option is a functional option for configuring the Client.
-type Option func(*Client)
+type Option func(*options)
 
 // WithBaseURL sets a custom base URL for the API.
 func WithBaseURL(url string) Option {
-	return func(c *Client) {
-		c.baseURL = url
+	return func(o *options) {
+		o.baseURLs = []string{url}
+	}
+}
+
+// WithEndpoints sets several base URLs for the API. Requests are routed to
+// the healthiest, lowest-latency endpoint and fail over to the next one on
+// connection errors and 5xx responses. With more than one endpoint the
+// client probes them in the background, and latency is measured by those
+// probes alone; call Close to stop probing.
+func WithEndpoints(urls ...string) Option {
+	return func(o *options) {
+		if len(urls) > 0 {
+			o.baseURLs = append([]string(nil), urls...)
+		}
+	}
+}
+
+// WithHealthCheckInterval sets how often endpoints configured with
+// WithEndpoints are probed. The default is 30 seconds.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.healthCheckInterval = interval
 	}
 }
 
-// WithHTTPClient sets a custom HTTP client.
+// WithHTTPClient sets a custom HTTP client. The client is copied, not
+// modified, so it can be shared with other code. Its Timeout is used
+// unless WithTimeout is also given.
 func WithHTTPClient(client *http.Client) Option {
-	return func(c *Client) {
-		c.httpClient = client
+	return func(o *options) {
+		o.httpClient = client
 	}
 }
 
-// WithTimeout sets the HTTP client timeout.
+// WithTimeout sets the timeout for each HTTP request. It can be overridden
+// per call with WithCallTimeout.
 func WithTimeout(timeout time.Duration) Option {
-	return func(c *Client) {
-		c.httpClient.Timeout = timeout
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry retries requests that failed on every endpoint with a
+// connection error or 5xx response up to maxRetries times, waiting backoff
+// before the first retry and doubling it each time.
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.maxRetries = maxRetries
+		o.retryBackoff = backoff
+	}
+}
+
+// WithRateLimit limits the client to requestsPerSecond requests, allowing
+// bursts of up to burst requests. Calls block until they may proceed or
+// their context is done.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(o *options) {
+		o.rateLimit = requestsPerSecond
+		o.rateBurst = burst
+	}
+}
+
+// WithDefaultProxy sets the proxy used by requests that do not set one
+// themselves.
+func WithDefaultProxy(proxy string) Option {
+	return func(o *options) {
+		o.defaultProxy = proxy
+	}
+}
+
+// WithMaxResponseSize limits the size of response bodies. Larger
+// responses fail with ErrResponseTooLarge. The default is 10 MiB.
+func WithMaxResponseSize(bytes int64) Option {
+	return func(o *options) {
+		o.maxBodySize = bytes
 	}
 }
 
 // NewClient creates a new Target API client.
 // The apiKey should be a valid Gatsbie API key starting with "gats_".
 func NewClient(apiKey string, opts ...Option) *Client {
-	c := &Client{
-		baseURL: defaultBaseURL,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
+	o := options{
+		baseURLs:     []string{defaultBaseURL},
+		retryBackoff: defaultRetryBackoff,
+		maxBodySize:  httpbody.DefaultMaxSize,
 	}
-
 	for _, opt := range opts {
-		opt(c)
+		opt(&o)
 	}
 
+	// Work on a copy of the caller's client so that neither WithTimeout
+	// nor anything else changes a client the caller may share. Timeouts
+	// are enforced per request through the context instead, which lets
+	// WithCallTimeout override them in either direction.
+	httpClient := &http.Client{}
+	timeout := defaultTimeout
+	if o.httpClient != nil {
+		clone := *o.httpClient
+		httpClient = &clone
+		timeout = o.httpClient.Timeout
+	}
+	httpClient.Timeout = 0
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+
+	c := &Client{
+		apiKey:       apiKey,
+		httpClient:   httpClient,
+		timeout:      timeout,
+		maxRetries:   o.maxRetries,
+		retryBackoff: o.retryBackoff,
+		defaultProxy: o.defaultProxy,
+		maxBodySize:  o.maxBodySize,
+		limiter:      ratelimit.New(o.rateLimit, o.rateBurst),
+		endpoints:    endpoint.New(o.baseURLs),
+	}
+	c.endpoints.Start(o.healthCheckInterval, defaultTimeout, c.probe)
+
 	return c
 }
 
-// do performs an HTTP request with authentication.
-func (c *Client) do(ctx context.Context, method, path string, body any, result any) error {
-	var reqBody io.Reader
+// Close stops background endpoint health checks. The client must not be
+// used after Close.
+func (c *Client) Close() error {
+	c.endpoints.Close()
+	return nil
+}
+
+// EndpointStatus describes the observed health of a configured endpoint.
+type EndpointStatus = endpoint.Status
+
+// Endpoints returns the current health of every configured endpoint.
+func (c *Client) Endpoints() []EndpointStatus {
+	return c.endpoints.Statuses()
+}
+
+// probe checks the health of a single endpoint.
+func (c *Client) probe(ctx context.Context, baseURL string) error {
+	var result HealthResponse
+	if _, err := c.doOnce(ctx, baseURL, http.MethodGet, "/health", nil, &result, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// do performs an HTTP request with authentication, failing over between
+// endpoints on connection errors and 5xx responses and retrying with
+// backoff if every endpoint failed. A request that is not idempotent,
+// such as adding to cart, is sent again only if it never reached the
+// server, since the API has no idempotency keys to deduplicate it.
+func (c *Client) do(ctx context.Context, method, path string, body any, result any, opts []CallOption) error {
+	var reqBody []byte
 	if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("target: failed to marshal request: %w", err)
 		}
-		reqBody = bytes.NewReader(data)
+		reqBody = data
+	}
+
+	// A call timeout bounds the whole call, retries included, and replaces
+	// the client's per-request timeout.
+	timeout := c.timeout
+	co := newCallOptions(opts)
+	if co.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, co.timeout)
+		defer cancel()
+		timeout = 0
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := retry.Sleep(ctx, retry.Backoff(c.retryBackoff, attempt)); err != nil {
+				return lastErr
+			}
+		}
+
+		for _, baseURL := range c.endpoints.Ordered() {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("target: rate limit: %w", err)
+			}
+
+			failover, err := c.doOnce(ctx, baseURL, method, path, reqBody, result, timeout)
+			if !failover {
+				if err == nil {
+					c.endpoints.MarkHealthy(baseURL)
+				}
+				return err
+			}
+			c.endpoints.MarkFailure(baseURL, err)
+			lastErr = err
+			if ctx.Err() != nil {
+				return lastErr
+			}
+		}
 	}
+	return lastErr
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+// doOnce performs a single request against baseURL. It reports whether the
+// failure is one that should be retried against another endpoint.
+func (c *Client) doOnce(ctx context.Context, baseURL, method, path string, body []byte, result any, timeout time.Duration) (bool, error) {
+	// The per-request timeout lives on its own context so that hitting it
+	// still counts as an endpoint failure rather than the caller giving up.
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(attemptCtx, method, baseURL+path, reqBody)
 	if err != nil {
-		return fmt.Errorf("target: failed to create request: %w", err)
+		return false, fmt.Errorf("target: failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
+	idempotent := idempotentMethod(method)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("target: request failed: %w", err)
+		failover := isConnectionError(ctx, err) && (idempotent || notSent(err))
+		return failover, fmt.Errorf("target: request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("target: failed to read response: %w", err)
-	}
-
 	// Check for error responses
 	if resp.StatusCode >= 400 {
+		failover := resp.StatusCode >= 500 && idempotent
+		respBody, truncated, err := httpbody.ReadPrefix(resp.Body, min(errorBodyLimit, c.maxBodySize))
+		if err != nil {
+			return isConnectionError(ctx, err) && idempotent, fmt.Errorf("target: failed to read response: %w", err)
+		}
 		var apiErr APIError
 		if err := json.Unmarshal(respBody, &apiErr); err != nil {
-			return &APIError{
-				Message:    fmt.Sprintf("unexpected error response: %s", string(respBody)),
+			return failover, &APIError{
+				Message:    fmt.Sprintf("unexpected error response: %s", httpbody.Snippet(respBody, errorSnippetSize, truncated)),
 				HTTPStatus: resp.StatusCode,
 			}
 		}
 		apiErr.HTTPStatus = resp.StatusCode
-		return &apiErr
+		return failover, &apiErr
 	}
 
 	if result != nil {
-		if err := json.Unmarshal(respBody, result); err != nil {
-			return fmt.Errorf("target: failed to unmarshal response: %w", err)
+		body := httpbody.NewReader(resp.Body, c.maxBodySize)
+		if err := json.NewDecoder(body).Decode(result); err != nil {
+			switch readErr := body.Err(); {
+			case errors.Is(readErr, httpbody.ErrTooLarge):
+				return false, fmt.Errorf("target: failed to read response: %w (limit %d bytes)", readErr, c.maxBodySize)
+			case readErr != nil:
+				return isConnectionError(ctx, readErr) && idempotent, fmt.Errorf("target: failed to read response: %w", readErr)
+			}
+			return false, fmt.Errorf("target: failed to unmarshal response: %w", err)
 		}
 	}
 
-	return nil
+	return false, nil
+}
+
+// isConnectionError reports whether err is a transport failure rather than
+// the caller giving up on the request.
+func isConnectionError(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil
+}
+
+// idempotentMethod reports whether a request with method can be sent
+// again after the server may have acted on it.
+func idempotentMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// notSent reports whether err shows the request never reached the server
+// because no connection could be made.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// proxy returns p, or the client's default proxy if p is empty.
+func (c *Client) proxy(p string) string {
+	if p == "" {
+		return c.defaultProxy
+	}
+	return p
 }
 
 // doGet performs a GET request.
-func (c *Client) doGet(ctx context.Context, path string, result any) error {
-	return c.do(ctx, http.MethodGet, path, nil, result)
+func (c *Client) doGet(ctx context.Context, path string, result any, opts []CallOption) error {
+	return c.do(ctx, http.MethodGet, path, nil, result, opts)
 }
 
 // doPost performs a POST request.
-func (c *Client) doPost(ctx context.Context, path string, body any, result any) error {
-	return c.do(ctx, http.MethodPost, path, body, result)
+func (c *Client) doPost(ctx context.Context, path string, body any, result any, opts []CallOption) error {
+	return c.do(ctx, http.MethodPost, path, body, result, opts)
 }
 
 // Health checks the API server health status.
-func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+func (c *Client) Health(ctx context.Context, opts ...CallOption) (*HealthResponse, error) {
 	var result HealthResponse
-	if err := c.doGet(ctx, "/health", &result); err != nil {
+	if err := c.doGet(ctx, "/health", &result, opts); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
 // Ping checks API connectivity and returns quota information.
-func (c *Client) Ping(ctx context.Context) (*PingResponse, error) {
+func (c *Client) Ping(ctx context.Context, opts ...CallOption) (*PingResponse, error) {
 	var result PingResponse
-	if err := c.doGet(ctx, "/api/v1/ping", &result); err != nil {
+	if err := c.doGet(ctx, "/api/v1/ping", &result, opts); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
 // GetNearbyStores returns a list of Target stores near the specified coordinates.
-func (c *Client) GetNearbyStores(ctx context.Context, req NearbyStoresRequest) ([]StoreResponse, error) {
+func (c *Client) GetNearbyStores(ctx context.Context, req NearbyStoresRequest, opts ...CallOption) ([]StoreResponse, error) {
 	params := url.Values{}
 	params.Set("lat", strconv.FormatFloat(req.Lat, 'f', -1, 64))
 	params.Set("lng", strconv.FormatFloat(req.Lng, 'f', -1, 64))
@@ -162,14 +409,15 @@ func (c *Client) GetNearbyStores(ctx context.Context, req NearbyStoresRequest) (
 	path := "/api/v1/stores/nearby?" + params.Encode()
 
 	var result []StoreResponse
-	if err := c.doGet(ctx, path, &result); err != nil {
+	if err := c.doGet(ctx, path, &result, opts); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
 // GetProduct returns detailed product information for a specific Target product.
-func (c *Client) GetProduct(ctx context.Context, req GetProductRequest) (*ProductResponse, error) {
+func (c *Client) GetProduct(ctx context.Context, req GetProductRequest, opts ...CallOption) (*ProductResponse, error) {
+	req.Proxy = c.proxy(req.Proxy)
 	if req.TCIN == "" {
 		return nil, &APIError{Message: "tcin is required", HTTPStatus: 400}
 	}
@@ -186,14 +434,15 @@ func (c *Client) GetProduct(ctx context.Context, req GetProductRequest) (*Produc
 	path := fmt.Sprintf("/api/v1/products/%s?%s", req.TCIN, params.Encode())
 
 	var result ProductResponse
-	if err := c.doGet(ctx, path, &result); err != nil {
+	if err := c.doGet(ctx, path, &result, opts); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
 // AddToCart adds an item to the Target shopping cart.
-func (c *Client) AddToCart(ctx context.Context, req AddToCartRequest) (*AddToCartResponse, error) {
+func (c *Client) AddToCart(ctx context.Context, req AddToCartRequest, opts ...CallOption) (*AddToCartResponse, error) {
+	req.Proxy = c.proxy(req.Proxy)
 	if req.TCIN == "" {
 		return nil, &APIError{Message: "tcin is required", HTTPStatus: 400}
 	}
@@ -226,7 +475,7 @@ func (c *Client) AddToCart(ctx context.Context, req AddToCartRequest) (*AddToCar
 	}
 
 	var result AddToCartResponse
-	if err := c.doPost(ctx, "/api/v1/cart/items", body, &result); err != nil {
+	if err := c.doPost(ctx, "/api/v1/cart/items", body, &result, opts); err != nil {
 		return nil, err
 	}
 	return &result, nil
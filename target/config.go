@@ -0,0 +1,77 @@
+package target
+
+import "github.com/jaygatsbie/gatsbiesdk-go/internal/config"
+
+// Config holds client settings loaded from the environment or a config
+// file. It is the same type as gatsbie.Config, so one file can configure
+// both clients; the Target client uses TargetBaseURLs and the first of
+// APIKeys.
+type Config = config.Config
+
+// ConfigError is returned for invalid configuration. Its Key field names
+// the offending setting.
+type ConfigError = config.Error
+
+// LoadConfig reads a JSON, YAML or TOML config file. It accepts the same
+// files and profile selection as gatsbie.LoadConfig; the Target base URL
+// is set with target_base_url or target_base_urls.
+func LoadConfig(path string, profile ...string) (*Config, error) {
+	name := ""
+	if len(profile) > 0 {
+		name = profile[0]
+	}
+	return config.Load(path, name)
+}
+
+// NewClientFromEnv creates a client configured from the same environment
+// variables as gatsbie.NewClientFromEnv, except that the base URL is read
+// from GATSBIE_TARGET_BASE_URL or GATSBIE_TARGET_BASE_URLS. Options passed
+// in opts are applied last.
+func NewClientFromEnv(opts ...Option) (*Client, error) {
+	cfg, err := ConfigFromEnv("", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewClientFromConfig(cfg, opts...)
+}
+
+// ConfigFromEnv returns the config NewClientFromEnv uses. configPath and
+// profile, if not empty, are used in place of GATSBIE_CONFIG and
+// GATSBIE_PROFILE, without changing the environment.
+func ConfigFromEnv(configPath, profile string) (*Config, error) {
+	return config.FromEnvFile(configPath, profile)
+}
+
+// NewClientFromConfig creates a client from cfg. Options passed in opts
+// are applied after the config and take precedence over it.
+func NewClientFromConfig(cfg *Config, opts ...Option) (*Client, error) {
+	if len(cfg.APIKeys) == 0 {
+		return nil, &ConfigError{Key: "api_key", Msg: "no API key configured"}
+	}
+	return NewClient(cfg.APIKeys[0], append(configOptions(cfg), opts...)...), nil
+}
+
+// configOptions converts cfg to the equivalent options.
+func configOptions(cfg *Config) []Option {
+	var opts []Option
+	if len(cfg.TargetBaseURLs) > 0 {
+		opts = append(opts, WithEndpoints(cfg.TargetBaseURLs...))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(cfg.Timeout))
+	}
+	if cfg.MaxRetries > 0 {
+		backoff := cfg.RetryBackoff
+		if backoff == 0 {
+			backoff = defaultRetryBackoff
+		}
+		opts = append(opts, WithRetry(cfg.MaxRetries, backoff))
+	}
+	if cfg.RateLimit > 0 {
+		opts = append(opts, WithRateLimit(cfg.RateLimit, cfg.RateBurst))
+	}
+	if cfg.Proxy != "" {
+		opts = append(opts, WithDefaultProxy(cfg.Proxy))
+	}
+	return opts
+}
@@ -10,14 +10,13 @@ import (
 )
 
 func main() {
-	// Get API key from environment
-	apiKey := os.Getenv("GATSBIE_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GATSBIE_API_KEY environment variable is required")
+	// Create client from the environment (GATSBIE_API_KEY,
+	// GATSBIE_TARGET_BASE_URL, GATSBIE_CONFIG, ...)
+	client, err := target.NewClientFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Create client
-	client := target.NewClient(apiKey)
+	defer client.Close()
 	ctx := context.Background()
 
 	// Health check
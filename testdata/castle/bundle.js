@@ -0,0 +1 @@
+!function(){"use strict";var e=window.__APP_CONFIG__||{};function t(n){return n&&n.__esModule?n.default:n}var r={"env":"production","castle":{"pk":"pk_H2kLp9sVq4XwZr7TbN1mCf6g","wUrl":"https:\/\/assets.example.com\/static\/c\/w.js","swUrl":"\/sw-castle.js","avoidCookies":!0}};t(e).castle.configure(r.castle)}();
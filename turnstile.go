@@ -0,0 +1,77 @@
+package gatsbie
+
+import (
+	"html"
+	"regexp"
+)
+
+// TurnstileWidget is a Turnstile widget found on a page.
+type TurnstileWidget struct {
+	SiteKey     string
+	Action      string
+	CData       string
+	ChlPageData string
+}
+
+// Request returns a TurnstileRequest for the widget.
+func (w TurnstileWidget) Request(proxy, targetURL string) *TurnstileRequest {
+	return &TurnstileRequest{
+		Proxy:       proxy,
+		TargetURL:   targetURL,
+		SiteKey:     w.SiteKey,
+		Action:      w.Action,
+		CData:       w.CData,
+		ChlPageData: w.ChlPageData,
+	}
+}
+
+var (
+	// An implicitly rendered widget: <div class="cf-turnstile" data-sitekey=...>.
+	turnstileDiv = regexp.MustCompile(`<[a-z]+\b[^>]*\bdata-sitekey\s*=\s*["']0x[^>]*>`)
+	// An explicitly rendered widget: turnstile.render(el, {sitekey: ...}).
+	turnstileRender = regexp.MustCompile(`turnstile\.render\([^{)]*\{([^}]*)\}`)
+	htmlAttr        = regexp.MustCompile(`([\w-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	jsProperty      = regexp.MustCompile(`["']?(\w+)["']?\s*:\s*(?:"([^"]*)"|'([^']*)'|` + "`([^`]*)`" + `)`)
+)
+
+// DetectTurnstile finds the first Turnstile widget in page, either in
+// cf-turnstile markup or in a turnstile.render call, and extracts its
+// site key, action, cData and chlPageData.
+func DetectTurnstile(page []byte) (TurnstileWidget, bool) {
+	var w TurnstileWidget
+	if tag := turnstileDiv.Find(page); tag != nil {
+		for _, m := range htmlAttr.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(string(m[2]) + string(m[3]))
+			switch string(m[1]) {
+			case "data-sitekey":
+				w.SiteKey = value
+			case "data-action":
+				w.Action = value
+			case "data-cdata":
+				w.CData = value
+			case "data-chl-page-data", "data-chlpagedata":
+				w.ChlPageData = value
+			}
+		}
+		return w, true
+	}
+	if m := turnstileRender.FindSubmatch(page); m != nil {
+		for _, p := range jsProperty.FindAllSubmatch(m[1], -1) {
+			value := string(p[2]) + string(p[3]) + string(p[4])
+			switch string(p[1]) {
+			case "sitekey":
+				w.SiteKey = value
+			case "action":
+				w.Action = value
+			case "cData":
+				w.CData = value
+			case "chlPageData":
+				w.ChlPageData = value
+			}
+		}
+		if w.SiteKey != "" {
+			return w, true
+		}
+	}
+	return TurnstileWidget{}, false
+}
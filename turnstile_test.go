@@ -0,0 +1,66 @@
+package gatsbie_test
+
+import (
+	"context"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+	"github.com/jaygatsbie/gatsbiesdk-go/gatsbietest"
+)
+
+func TestDetectTurnstile(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    gatsbie.TurnstileWidget
+	}{
+		{"implicit.html", gatsbie.TurnstileWidget{
+			SiteKey: "0x4AAAAAAABS7TtLxsNa7Z2e",
+			Action:  "login",
+			CData:   "session-7f3a9c",
+		}},
+		{"explicit.html", gatsbie.TurnstileWidget{
+			SiteKey:     "0x4AAAAAAADnPIDROrmt1Wwj",
+			Action:      "checkout",
+			CData:       "order-1234",
+			ChlPageData: "3gAFo2l2MbhQMkpIN3ZpcDZqb3VqTnFzOWZ5aGJnPT0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, ok := gatsbie.DetectTurnstile(readFixture(t, "turnstile", tt.fixture))
+			if !ok || got != tt.want {
+				t.Errorf("DetectTurnstile = %+v, %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := gatsbie.DetectTurnstile(readFixture(t, "akamai", "none.html")); ok {
+		t.Error("DetectTurnstile found a widget on a page without one")
+	}
+}
+
+func TestTurnstileWidgetFieldsAreSent(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	w, ok := gatsbie.DetectTurnstile(readFixture(t, "turnstile", "explicit.html"))
+	if !ok {
+		t.Fatal("no widget found")
+	}
+	if _, err := srv.Client().SolveTurnstile(context.Background(), w.Request(testProxy, "https://example.com/checkout")); err != nil {
+		t.Fatal(err)
+	}
+	srv.ExpectField(gatsbietest.TaskTurnstile, "site_key", "0x4AAAAAAADnPIDROrmt1Wwj")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "target_url", "https://example.com/checkout")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "action", "checkout")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "cdata", "order-1234")
+	srv.ExpectField(gatsbietest.TaskTurnstile, "chl_page_data", "3gAFo2l2MbhQMkpIN3ZpcDZqb3VqTnFzOWZ5aGJnPT0")
+}
+
+func TestTurnstileOptionalFieldsOmitted(t *testing.T) {
+	srv := gatsbietest.NewServer(t)
+	if _, err := srv.Client().SolveTurnstile(context.Background(), &gatsbie.TurnstileRequest{SiteKey: "0x4AAA"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"action", "cdata", "chl_page_data"} {
+		srv.ExpectNoField(gatsbietest.TaskTurnstile, field)
+	}
+}
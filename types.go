@@ -1,5 +1,10 @@
 package gatsbie
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // HealthResponse is returned by the health check endpoint.
 type HealthResponse struct {
 	Status string `json:"status"`
@@ -13,6 +18,24 @@ type SolveResponse[T any] struct {
 	Solution  T       `json:"solution"`
 	Cost      float64 `json:"cost"`
 	SolveTime float64 `json:"solveTime"`
+
+	// IdempotencyKey is the key the request was sent with. Retries of the
+	// same call reuse it, so the API returns the cached result instead of
+	// charging again.
+	IdempotencyKey string `json:"-"`
+
+	// RawSolution is the solution object exactly as the API returned it,
+	// including fields that T does not define.
+	RawSolution json.RawMessage `json:"-"`
+
+	// Raw is the whole response body.
+	Raw json.RawMessage `json:"-"`
+
+	// ReceivedAt is when the Client received the response. ExpiresAt
+	// measures default lifetimes from it. It is serialized, so a cached
+	// response keeps its age; it is zero for a response decoded from an
+	// API body outside a Client, which therefore counts as expired.
+	ReceivedAt time.Time `json:"receivedAt,omitempty"`
 }
 
 // DatadomeRequest is the request for solving Datadome device check challenges.
@@ -37,6 +60,16 @@ type RecaptchaRequest struct {
 	Title     string `json:"title"`
 	Action    string `json:"action,omitempty"`
 	Ubd       bool   `json:"ubd,omitempty"`
+
+	// MinScore is the lowest acceptable v3 score, from 0 to 1. Solutions
+	// scoring below it are solved again, up to MaxAttempts times in all.
+	// If none reaches it, or a re-solve fails, the best solution so far is
+	// returned along with an error wrapping ErrScoreTooLow.
+	MinScore float64 `json:"min_score,omitempty"`
+
+	// MaxAttempts bounds the solves made to reach MinScore. Zero means
+	// DefaultRecaptchaAttempts. It is not sent to the API.
+	MaxAttempts int `json:"-"`
 }
 
 // RecaptchaEnterpriseRequest is the request for solving reCAPTCHA Enterprise challenges.
@@ -49,11 +82,24 @@ type RecaptchaEnterpriseRequest struct {
 	Action    string `json:"action,omitempty"`
 	Ubd       bool   `json:"ubd,omitempty"`
 	Sa        string `json:"sa,omitempty"`
+
+	// MinScore and MaxAttempts are as for RecaptchaRequest.
+	MinScore    float64 `json:"min_score,omitempty"`
+	MaxAttempts int     `json:"-"`
 }
 
 // RecaptchaSolution is returned when solving reCAPTCHA challenges.
 type RecaptchaSolution struct {
 	Token string `json:"token"`
+
+	// Score is the v3 score the API measured for the token, if it
+	// reported one.
+	Score *float64 `json:"score,omitempty"`
+
+	// Attempts is the number of solves made to meet MinScore, and
+	// TotalCost the credits charged for all of them.
+	Attempts  int     `json:"-"`
+	TotalCost float64 `json:"-"`
 }
 
 // AkamaiRequest is the request for solving Akamai challenges.
@@ -70,6 +116,9 @@ type AkamaiCookies struct {
 	UsrLocale string `json:"UsrLocale,omitempty"`
 	Abck      string `json:"_abck"`
 	BmSz      string `json:"bm_sz"`
+
+	// Extra holds cookies the API returned that have no field above.
+	Extra map[string]any `json:"-"`
 }
 
 // AkamaiSolution is returned when solving Akamai challenges.
@@ -107,19 +156,39 @@ type ShapeSolution map[string]string
 
 // ShapeV2Request is the request for solving Shape v2 challenges.
 type ShapeV2Request struct {
-	URL       string            `json:"url"`
-	Proxy     string            `json:"proxy"`
-	Pkey      string            `json:"pkey,omitempty"`
-	ScriptURL string            `json:"script_url,omitempty"`
-	Request   map[string]string `json:"request,omitempty"`
-	Country   string            `json:"country,omitempty"`
-	Timeout   int               `json:"timeout,omitempty"`
+	URL       string                   `json:"url"`
+	Proxy     string                   `json:"proxy"`
+	Pkey      string                   `json:"pkey,omitempty"`
+	ScriptURL string                   `json:"script_url,omitempty"`
+	Request   *ShapeV2ProtectedRequest `json:"request,omitempty"`
+	Country   string                   `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, such as "us"
+	Timeout   int                      `json:"timeout,omitempty"` // seconds
+}
+
+// ShapeV2ProtectedRequest describes the protected API call that the Shape
+// headers are generated for.
+type ShapeV2ProtectedRequest struct {
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
 }
 
 // ShapeV2Solution is returned when solving Shape v2 challenges.
 type ShapeV2Solution struct {
-	Headers map[string]string      `json:"headers,omitempty"`
-	Extra   map[string]interface{} `json:"extra,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Extra   ShapeV2Extra      `json:"extra,omitempty"`
+}
+
+// ShapeV2Extra holds additional data returned with Shape v2 headers.
+type ShapeV2Extra struct {
+	Cookie    string            `json:"cookie,omitempty"` // Cookie header value to send with the headers
+	Cookies   map[string]string `json:"cookies,omitempty"`
+	UserAgent string            `json:"ua,omitempty"`
+
+	// Other holds members that have no field above.
+	Other map[string]any `json:"-"`
 }
 
 // TurnstileRequest is the request for solving Cloudflare Turnstile challenges.
@@ -127,6 +196,13 @@ type TurnstileRequest struct {
 	Proxy     string `json:"proxy"`
 	TargetURL string `json:"target_url"`
 	SiteKey   string `json:"site_key"`
+
+	// Action, CData and ChlPageData are the widget's data-action,
+	// data-cdata and chlPageData values. Sites that set them reject
+	// tokens solved without them.
+	Action      string `json:"action,omitempty"`
+	CData       string `json:"cdata,omitempty"`
+	ChlPageData string `json:"chl_page_data,omitempty"`
 }
 
 // TurnstileSolution is returned when solving Cloudflare Turnstile challenges.
@@ -149,6 +225,9 @@ type PerimeterXCookies struct {
 	Pxde  string `json:"_pxde"`
 	Pxvid string `json:"_pxvid"`
 	Pxcts string `json:"pxcts"`
+
+	// Extra holds cookies the API returned that have no field above.
+	Extra map[string]any `json:"-"`
 }
 
 // PerimeterXSolution is returned when solving PerimeterX challenges.
@@ -162,11 +241,26 @@ type CloudflareWAFRequest struct {
 	Proxy        string `json:"proxy"`
 	TargetURL    string `json:"target_url"`
 	TargetMethod string `json:"target_method"`
+
+	// TargetBody and TargetHeaders are the body and headers of the
+	// request that was challenged, for challenges on non-GET requests.
+	TargetBody    string            `json:"target_body,omitempty"`
+	TargetHeaders map[string]string `json:"target_headers,omitempty"`
 }
 
 // CloudflareWAFCookies contains the cookies returned by Cloudflare WAF.
 type CloudflareWAFCookies struct {
 	CfClearance string `json:"cf_clearance"`
+	CfBm        string `json:"__cf_bm,omitempty"`
+
+	// Extra holds cookies the API returned that have no field above.
+	Extra map[string]any `json:"-"`
+
+	// ClearanceExpires and ClearanceMaxAge are the Expires and Max-Age
+	// attributes of cf_clearance, when the API returns it as a Set-Cookie
+	// line; CfClearance then holds only the cookie value.
+	ClearanceExpires time.Time `json:"-"`
+	ClearanceMaxAge  int       `json:"-"`
 }
 
 // CloudflareWAFSolution is returned when solving Cloudflare WAF challenges.
@@ -199,6 +293,9 @@ type CaptchaFoxRequest struct {
 type CaptchaFoxCookies struct {
 	BmS  string `json:"bm_s"`
 	BmSc string `json:"bm_sc"`
+
+	// Extra holds cookies the API returned that have no field above.
+	Extra map[string]any `json:"-"`
 }
 
 // CaptchaFoxSolution is returned when solving CaptchaFox challenges.
@@ -232,12 +329,19 @@ type CastleSolution struct {
 type Reese84Request struct {
 	Proxy        string `json:"proxy"`
 	Reese84JsUrl string `json:"reese84_js_url"`
+	TargetURL    string `json:"target_url,omitempty"` // the protected page
 }
 
 // Reese84Solution is returned when solving Reese84 challenges.
 type Reese84Solution struct {
 	Reese84   string `json:"reese84"`
 	UserAgent string `json:"user_agent"`
+
+	// RenewInSec is how many seconds the token is valid before it must be
+	// renewed, and CookieDomain the domain to set the reese84 cookie on,
+	// when the API reports them.
+	RenewInSec   int    `json:"renewInSec,omitempty"`
+	CookieDomain string `json:"cookieDomain,omitempty"`
 }
 
 // ForterRequest is the request for solving Forter challenges.
@@ -260,6 +364,17 @@ type FuncaptchaRequest struct {
 	TargetURL     string `json:"target_url"`
 	CustomApiHost string `json:"custom_api_host"`
 	PublicKey     string `json:"public_key"`
+
+	// Blob is the data[blob] value the site passes to Arkose, required on
+	// many signup and login flows.
+	Blob string `json:"blob,omitempty"`
+
+	// Language is the widget language, such as "en" or "de".
+	Language string `json:"language,omitempty"`
+
+	// ExtraData holds further data[...] entries sent to Arkose, keyed
+	// without the data prefix.
+	ExtraData map[string]string `json:"extra_data,omitempty"`
 }
 
 // FuncaptchaSolution is returned when solving Funcaptcha challenges.
@@ -282,6 +397,22 @@ type SBSDSolution struct {
 	UserAgent string `json:"ua"`
 }
 
+// PerimeterXHoldRequest is the request for solving PerimeterX press-and-hold challenges.
+type PerimeterXHoldRequest struct {
+	Proxy        string `json:"proxy"`
+	TargetURL    string `json:"target_url"`
+	PxAppID      string `json:"pxAppId"`
+	CaptchaJSURL string `json:"captcha_js_url"` // the block page's captcha.js
+	UUID         string `json:"uuid,omitempty"` // the block's uuid
+	VID          string `json:"vid,omitempty"`  // the block's vid
+}
+
+// PerimeterXHoldSolution is returned when solving PerimeterX press-and-hold challenges.
+type PerimeterXHoldSolution struct {
+	Cookies   PerimeterXCookies `json:"perimeterx_cookies"`
+	UserAgent string            `json:"ua"`
+}
+
 // internal request wrappers that include task_type
 type datadomeRequestInternal struct {
 	TaskType     string `json:"task_type"`
@@ -291,26 +422,28 @@ type datadomeRequestInternal struct {
 }
 
 type recaptchaRequestInternal struct {
-	TaskType  string `json:"task_type"`
-	Proxy     string `json:"proxy"`
-	TargetURL string `json:"target_url"`
-	SiteKey   string `json:"site_key"`
-	Size      string `json:"size"`
-	Title     string `json:"title"`
-	Action    string `json:"action,omitempty"`
-	Ubd       bool   `json:"ubd,omitempty"`
+	TaskType  string  `json:"task_type"`
+	Proxy     string  `json:"proxy"`
+	TargetURL string  `json:"target_url"`
+	SiteKey   string  `json:"site_key"`
+	Size      string  `json:"size"`
+	Title     string  `json:"title"`
+	Action    string  `json:"action,omitempty"`
+	Ubd       bool    `json:"ubd,omitempty"`
+	MinScore  float64 `json:"min_score,omitempty"`
 }
 
 type recaptchaEnterpriseRequestInternal struct {
-	TaskType  string `json:"task_type"`
-	Proxy     string `json:"proxy"`
-	TargetURL string `json:"target_url"`
-	SiteKey   string `json:"site_key"`
-	Size      string `json:"size"`
-	Title     string `json:"title"`
-	Action    string `json:"action,omitempty"`
-	Ubd       bool   `json:"ubd,omitempty"`
-	Sa        string `json:"sa,omitempty"`
+	TaskType  string  `json:"task_type"`
+	Proxy     string  `json:"proxy"`
+	TargetURL string  `json:"target_url"`
+	SiteKey   string  `json:"site_key"`
+	Size      string  `json:"size"`
+	Title     string  `json:"title"`
+	Action    string  `json:"action,omitempty"`
+	Ubd       bool    `json:"ubd,omitempty"`
+	Sa        string  `json:"sa,omitempty"`
+	MinScore  float64 `json:"min_score,omitempty"`
 }
 
 type akamaiRequestInternal struct {
@@ -337,11 +470,28 @@ type shapeRequestInternal struct {
 	Method     string `json:"method"`
 }
 
+type shapeV2RequestInternal struct {
+	URL      string          `json:"url"`
+	Metadata shapeV2Metadata `json:"metadata"`
+}
+
+type shapeV2Metadata struct {
+	Proxy     string                   `json:"proxy"`
+	Pkey      string                   `json:"pkey,omitempty"`
+	ScriptURL string                   `json:"script_url,omitempty"`
+	Request   *ShapeV2ProtectedRequest `json:"request,omitempty"`
+	Country   string                   `json:"country,omitempty"`
+	Timeout   int                      `json:"timeout,omitempty"`
+}
+
 type turnstileRequestInternal struct {
-	TaskType  string `json:"task_type"`
-	Proxy     string `json:"proxy"`
-	TargetURL string `json:"target_url"`
-	SiteKey   string `json:"site_key"`
+	TaskType    string `json:"task_type"`
+	Proxy       string `json:"proxy"`
+	TargetURL   string `json:"target_url"`
+	SiteKey     string `json:"site_key"`
+	Action      string `json:"action,omitempty"`
+	CData       string `json:"cdata,omitempty"`
+	ChlPageData string `json:"chl_page_data,omitempty"`
 }
 
 type perimeterXRequestInternal struct {
@@ -353,10 +503,12 @@ type perimeterXRequestInternal struct {
 }
 
 type cloudflareWAFRequestInternal struct {
-	TaskType     string `json:"task_type"`
-	Proxy        string `json:"proxy"`
-	TargetURL    string `json:"target_url"`
-	TargetMethod string `json:"target_method"`
+	TaskType      string            `json:"task_type"`
+	Proxy         string            `json:"proxy"`
+	TargetURL     string            `json:"target_url"`
+	TargetMethod  string            `json:"target_method"`
+	TargetBody    string            `json:"target_body,omitempty"`
+	TargetHeaders map[string]string `json:"target_headers,omitempty"`
 }
 
 type datadomeSliderRequestInternal struct {
@@ -384,6 +536,7 @@ type reese84RequestInternal struct {
 	TaskType     string `json:"task_type"`
 	Proxy        string `json:"proxy"`
 	Reese84JsUrl string `json:"reese84_js_url"`
+	TargetURL    string `json:"target_url,omitempty"`
 }
 
 type forterRequestInternal struct {
@@ -395,11 +548,14 @@ type forterRequestInternal struct {
 }
 
 type funcaptchaRequestInternal struct {
-	TaskType      string `json:"task_type"`
-	Proxy         string `json:"proxy"`
-	TargetURL     string `json:"target_url"`
-	CustomApiHost string `json:"custom_api_host"`
-	PublicKey     string `json:"public_key"`
+	TaskType      string            `json:"task_type"`
+	Proxy         string            `json:"proxy"`
+	TargetURL     string            `json:"target_url"`
+	CustomApiHost string            `json:"custom_api_host"`
+	PublicKey     string            `json:"public_key"`
+	Blob          string            `json:"blob,omitempty"`
+	Language      string            `json:"language,omitempty"`
+	ExtraData     map[string]string `json:"extra_data,omitempty"`
 }
 
 type sbsdRequestInternal struct {
@@ -408,3 +564,13 @@ type sbsdRequestInternal struct {
 	TargetURL    string `json:"target_url"`
 	TargetMethod string `json:"target_method"`
 }
+
+type perimeterXHoldRequestInternal struct {
+	TaskType     string `json:"task_type"`
+	Proxy        string `json:"proxy"`
+	TargetURL    string `json:"target_url"`
+	PxAppID      string `json:"pxAppId"`
+	CaptchaJSURL string `json:"captcha_js_url"`
+	UUID         string `json:"uuid,omitempty"`
+	VID          string `json:"vid,omitempty"`
+}
@@ -0,0 +1,192 @@
+package gatsbie_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	gatsbie "github.com/jaygatsbie/gatsbiesdk-go"
+)
+
+func TestTypesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"HealthResponse", &gatsbie.HealthResponse{Status: "ok"}},
+		{"DatadomeRequest", &gatsbie.DatadomeRequest{Proxy: testProxy, TargetURL: "https://a.example", TargetMethod: "GET"}},
+		{"DatadomeSolution", &gatsbie.DatadomeSolution{Datadome: "dd", UserAgent: "ua"}},
+		{"RecaptchaRequest", &gatsbie.RecaptchaRequest{Proxy: testProxy, TargetURL: "https://a.example", SiteKey: "k", Size: "invisible", Title: "t", Action: "a", Ubd: true}},
+		{"RecaptchaEnterpriseRequest", &gatsbie.RecaptchaEnterpriseRequest{Proxy: testProxy, TargetURL: "https://a.example", SiteKey: "k", Size: "normal", Title: "t", Action: "a", Ubd: true, Sa: "s"}},
+		{"RecaptchaSolution", &gatsbie.RecaptchaSolution{Token: "tok"}},
+		{"AkamaiRequest", &gatsbie.AkamaiRequest{Proxy: testProxy, TargetURL: "https://a.example", AkamaiJSURL: "https://a.example/akam", PageFP: "fp"}},
+		{"AkamaiSolution", &gatsbie.AkamaiSolution{CookiesDict: gatsbie.AkamaiCookies{Country: "US", UsrLocale: "en_US", Abck: "abck", BmSz: "bmsz"}, UserAgent: "ua"}},
+		{"VercelRequest", &gatsbie.VercelRequest{Proxy: testProxy, TargetURL: "https://a.example"}},
+		{"VercelSolution", &gatsbie.VercelSolution{Vcrcs: "v", UserAgent: "ua"}},
+		{"ShapeRequest", &gatsbie.ShapeRequest{Proxy: testProxy, TargetURL: "https://a.example", TargetAPI: "https://a.example/api", ShapeJSURL: "https://a.example/s.js", Title: "t", Method: "POST"}},
+		{"ShapeSolution", &gatsbie.ShapeSolution{"X-A": "a"}},
+		{"ShapeV2Request", &gatsbie.ShapeV2Request{URL: "https://a.example", Proxy: testProxy, Pkey: "p", ScriptURL: "https://a.example/s.js", Request: &gatsbie.ShapeV2ProtectedRequest{Method: "POST", URL: "https://a.example/api", Headers: map[string]string{"A": "b"}, Body: "{}", Cookies: map[string]string{"c": "d"}}, Country: "us", Timeout: 30}},
+		{"ShapeV2Solution", &gatsbie.ShapeV2Solution{Headers: map[string]string{"X-A": "a"}, Extra: gatsbie.ShapeV2Extra{Cookie: "c", Cookies: map[string]string{"a": "b"}, UserAgent: "ua", Other: map[string]any{"k": "v"}}}},
+		{"TurnstileRequest", &gatsbie.TurnstileRequest{Proxy: testProxy, TargetURL: "https://a.example", SiteKey: "k"}},
+		{"TurnstileSolution", &gatsbie.TurnstileSolution{Token: "tok", UserAgent: "ua"}},
+		{"PerimeterXRequest", &gatsbie.PerimeterXRequest{Proxy: testProxy, TargetURL: "https://a.example", PerimeterXJSURL: "https://a.example/px.js", PxAppID: "PXa"}},
+		{"PerimeterXSolution", &gatsbie.PerimeterXSolution{Cookies: gatsbie.PerimeterXCookies{Px3: "3", Pxde: "de", Pxvid: "vid", Pxcts: "cts"}, UserAgent: "ua"}},
+		{"CloudflareWAFRequest", &gatsbie.CloudflareWAFRequest{Proxy: testProxy, TargetURL: "https://a.example", TargetMethod: "GET"}},
+		{"CloudflareWAFSolution", &gatsbie.CloudflareWAFSolution{Cookies: gatsbie.CloudflareWAFCookies{CfClearance: "cf"}, UserAgent: "ua"}},
+		{"DatadomeSliderRequest", &gatsbie.DatadomeSliderRequest{Proxy: testProxy, TargetURL: "https://a.example", TargetMethod: "GET"}},
+		{"DatadomeSliderSolution", &gatsbie.DatadomeSliderSolution{Datadome: "dd", UserAgent: "ua"}},
+		{"CaptchaFoxRequest", &gatsbie.CaptchaFoxRequest{Proxy: testProxy, TargetURL: "https://a.example", SiteKey: "k"}},
+		{"CaptchaFoxSolution", &gatsbie.CaptchaFoxSolution{Cookie: gatsbie.CaptchaFoxCookies{BmS: "s", BmSc: "sc"}, UserAgent: "ua"}},
+		{"CastleRequest", &gatsbie.CastleRequest{Proxy: testProxy, TargetURL: "https://a.example", ConfigJSON: gatsbie.CastleConfigJSON{AvoidCookies: true, PK: "pk", WUrl: "w", SwUrl: "sw"}}},
+		{"CastleSolution", &gatsbie.CastleSolution{Token: "tok", UserAgent: "ua"}},
+		{"Reese84Request", &gatsbie.Reese84Request{Proxy: testProxy, Reese84JsUrl: "https://a.example/r.js"}},
+		{"Reese84Solution", &gatsbie.Reese84Solution{Reese84: "r", UserAgent: "ua"}},
+		{"ForterRequest", &gatsbie.ForterRequest{Proxy: testProxy, TargetURL: "https://a.example", ForterJsUrl: "https://a.example/f.js", SiteID: "s"}},
+		{"ForterSolution", &gatsbie.ForterSolution{Token: "tok", UserAgent: "ua"}},
+		{"FuncaptchaRequest", &gatsbie.FuncaptchaRequest{Proxy: testProxy, TargetURL: "https://a.example", CustomApiHost: "h", PublicKey: "pk"}},
+		{"FuncaptchaSolution", &gatsbie.FuncaptchaSolution{Token: "tok", UserAgent: "ua"}},
+		{"SBSDRequest", &gatsbie.SBSDRequest{Proxy: testProxy, TargetURL: "https://a.example", TargetMethod: "GET"}},
+		{"SBSDSolution", &gatsbie.SBSDSolution{BmS: "s", BmSc: "sc", UserAgent: "ua"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			got := reflect.New(reflect.TypeOf(tt.v).Elem()).Interface()
+			if err := json.Unmarshal(data, got); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.v) {
+				t.Errorf("round trip changed value\n got: %#v\nwant: %#v\njson: %s", got, tt.v, data)
+			}
+		})
+	}
+}
+
+func TestSolutionUserAgentField(t *testing.T) {
+	// Reese84 is the only solution that uses user_agent; every other
+	// solution with a user agent uses ua.
+	var reese gatsbie.Reese84Solution
+	if err := json.Unmarshal([]byte(`{"reese84":"r","user_agent":"right","ua":"wrong"}`), &reese); err != nil {
+		t.Fatal(err)
+	}
+	if reese.UserAgent != "right" {
+		t.Errorf("Reese84Solution.UserAgent = %q, want right", reese.UserAgent)
+	}
+
+	var turnstile gatsbie.TurnstileSolution
+	if err := json.Unmarshal([]byte(`{"token":"t","ua":"right","user_agent":"wrong"}`), &turnstile); err != nil {
+		t.Fatal(err)
+	}
+	if turnstile.UserAgent != "right" {
+		t.Errorf("TurnstileSolution.UserAgent = %q, want right", turnstile.UserAgent)
+	}
+}
+
+func TestSolveResponseKeepsRawJSON(t *testing.T) {
+	body := `{"success":true,"taskId":"task_1","service":"akamai","solution":{"cookies_dict":{"_abck":"a","bm_sz":"b"},"ua":"ua","new_field":1},"cost":0.01,"solveTime":12.5,"region":"eu"}`
+
+	var resp gatsbie.SolveResponse[gatsbie.AkamaiSolution]
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.TaskID != "task_1" || resp.Cost != 0.01 || resp.SolveTime != 12.5 {
+		t.Errorf("resp = %+v", resp)
+	}
+	if resp.Solution.CookiesDict.Abck != "a" || resp.Solution.UserAgent != "ua" {
+		t.Errorf("Solution = %+v", resp.Solution)
+	}
+	if string(resp.Raw) != body {
+		t.Errorf("Raw = %s", resp.Raw)
+	}
+	var solution map[string]any
+	if err := json.Unmarshal(resp.RawSolution, &solution); err != nil {
+		t.Fatal(err)
+	}
+	if solution["new_field"] != float64(1) {
+		t.Errorf("RawSolution = %s, want new_field kept", resp.RawSolution)
+	}
+}
+
+func TestCookiesExtra(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		v     interface{ extra() map[string]any }
+		extra map[string]any
+	}{
+		{
+			name:  "akamai",
+			json:  `{"_abck":"a","bm_sz":"b","bm_sv":"sv","ak_bmsc":"ak"}`,
+			v:     &akamaiCookies{},
+			extra: map[string]any{"bm_sv": "sv", "ak_bmsc": "ak"},
+		},
+		{
+			name:  "cloudflare",
+			json:  `{"cf_clearance":"cf","__cf_bm":"bm","_cfuvid":"uv"}`,
+			v:     &cloudflareCookies{},
+			extra: map[string]any{"_cfuvid": "uv"},
+		},
+		{
+			name:  "perimeterx",
+			json:  `{"_px3":"3","_pxhd":"hd"}`,
+			v:     &perimeterXCookies{},
+			extra: map[string]any{"_pxhd": "hd"},
+		},
+		{
+			name:  "captchafox",
+			json:  `{"bm_s":"s","bm_sc":"sc","bm_so":"so"}`,
+			v:     &captchaFoxCookies{},
+			extra: map[string]any{"bm_so": "so"},
+		},
+		{
+			name: "no extra",
+			json: `{"cf_clearance":"cf"}`,
+			v:    &cloudflareCookies{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(tt.json), tt.v); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.v.extra(), tt.extra) {
+				t.Errorf("Extra = %v, want %v", tt.v.extra(), tt.extra)
+			}
+
+			// Extra cookies are written back out, so they survive being
+			// stored and reloaded.
+			data, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want map[string]any
+			json.Unmarshal(data, &got)
+			json.Unmarshal([]byte(tt.json), &want)
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("marshalled %s = %v, want %v (json %s)", k, got[k], v, data)
+				}
+			}
+		})
+	}
+}
+
+type akamaiCookies struct{ gatsbie.AkamaiCookies }
+
+func (c *akamaiCookies) extra() map[string]any { return c.Extra }
+
+type cloudflareCookies struct{ gatsbie.CloudflareWAFCookies }
+
+func (c *cloudflareCookies) extra() map[string]any { return c.Extra }
+
+type perimeterXCookies struct{ gatsbie.PerimeterXCookies }
+
+func (c *perimeterXCookies) extra() map[string]any { return c.Extra }
+
+type captchaFoxCookies struct{ gatsbie.CaptchaFoxCookies }
+
+func (c *captchaFoxCookies) extra() map[string]any { return c.Extra }